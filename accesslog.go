@@ -0,0 +1,152 @@
+package vanityurl
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// AccessLogOptions for additional access log configuration.
+type AccessLogOptions struct {
+	// TrustedProxies allowed to set client IP with Forwarded or X-Forwarded-For header.
+	TrustedProxies []netip.Prefix
+}
+
+type requestInfoKey struct{}
+
+// requestInfo is filled by [Server] with resolved package for access log.
+type requestInfo struct {
+	pkgPath string
+	subpath string
+}
+
+func setRequestInfo(ctx context.Context, pkgPath, subpath string) {
+	if info, ok := ctx.Value(requestInfoKey{}).(*requestInfo); ok {
+		info.pkgPath = pkgPath
+		info.subpath = subpath
+	}
+}
+
+// NewAccessLogHandler wraps handler to log every request to a given logger.
+// Resolved package and subpath are logged when handler is a [Server].
+func NewAccessLogHandler(next http.Handler, logger *slog.Logger, opts *AccessLogOptions) http.Handler {
+	if opts == nil {
+		opts = &AccessLogOptions{}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		info := &requestInfo{}
+		sw := &statusWriter{ResponseWriter: w}
+
+		next.ServeHTTP(sw, r.WithContext(context.WithValue(r.Context(), requestInfoKey{}, info)))
+
+		logger.LogAttrs(r.Context(), slog.LevelInfo, "Request",
+			slog.String("method", r.Method),
+			slog.String("host", r.Host),
+			slog.String("path", r.URL.Path),
+			slog.String("package", info.pkgPath),
+			slog.String("subpath", info.subpath),
+			slog.Int("status", sw.Status()),
+			slog.Int64("bytes", sw.bytes),
+			slog.Duration("duration", time.Since(start)),
+			slog.String("user_agent", r.UserAgent()),
+			slog.String("remote_ip", clientIP(r, opts.TrustedProxies).String()),
+			slog.Bool("go_get", isGoGet(r)),
+		)
+	})
+}
+
+// clientIP returns IP address of the client. Forwarded header, or X-Forwarded-For if it is missing,
+// is used only if request comes from trusted proxies, right-most untrusted address is the client.
+// Peers without IP address, like proxies connected over unix socket, are always trusted.
+func clientIP(r *http.Request, trusted []netip.Prefix) netip.Addr {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err == nil {
+		addr = addr.Unmap()
+
+		if !isTrusted(addr, trusted) {
+			return addr
+		}
+	}
+
+	hops := forwardedHops(r)
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+
+		addr = hop.Unmap()
+
+		if !isTrusted(addr, trusted) {
+			break
+		}
+	}
+
+	return addr
+}
+
+// forwardedHops returns client addresses added by proxies, from Forwarded "for" parameters
+// or X-Forwarded-For header. Obfuscated and missing addresses are kept as not parsable.
+func forwardedHops(r *http.Request) []string {
+	elements := r.Header.Values("Forwarded")
+	if len(elements) == 0 {
+		return strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	}
+
+	var hops []string
+
+	for _, element := range strings.Split(strings.Join(elements, ","), ",") {
+		var hop string
+
+		for _, pair := range strings.Split(element, ";") {
+			key, value, _ := strings.Cut(strings.TrimSpace(pair), "=")
+			if strings.EqualFold(key, "for") {
+				hop = forwardedNode(value)
+			}
+		}
+
+		hops = append(hops, hop)
+	}
+
+	return hops
+}
+
+// forwardedNode returns address of Forwarded node without quotes, brackets and port,
+// e.g. "[2001:db8::1]:4711" or 192.0.2.1:4711.
+func forwardedNode(node string) string {
+	node = strings.Trim(node, `"`)
+
+	if rest, ok := strings.CutPrefix(node, "["); ok {
+		addr, _, _ := strings.Cut(rest, "]")
+
+		return addr
+	}
+
+	if host, _, err := net.SplitHostPort(node); err == nil {
+		return host
+	}
+
+	return node
+}
+
+func isTrusted(addr netip.Addr, trusted []netip.Prefix) bool {
+	for _, prefix := range trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
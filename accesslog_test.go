@@ -0,0 +1,160 @@
+package vanityurl_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"go.wamod.dev/vanityurl"
+)
+
+func TestNewAccessLogHandler(t *testing.T) {
+	srv := vanityurl.NewServer(
+		mustResolver(t, vanityurl.Package{
+			Path:          "/foo",
+			RepositoryURL: "https://github.com/example/foo",
+		}),
+		&vanityurl.ServerOptions{
+			Host: "go.example.com",
+		},
+	)
+
+	trusted := &vanityurl.AccessLogOptions{
+		TrustedProxies: []netip.Prefix{
+			netip.MustParsePrefix("10.0.0.0/8"),
+		},
+	}
+
+	tt := []struct {
+		name          string
+		opts          *vanityurl.AccessLogOptions
+		target        string
+		remoteAddr    string
+		forwardedFor  string
+		forwarded     string
+		wantStatus    float64
+		wantPackage   string
+		wantSubpath   string
+		wantRemoteIP  string
+		wantGoGetFlag bool
+	}{
+		{
+			name:          "go_get",
+			target:        "/foo/bar?go-get=1",
+			remoteAddr:    "192.0.2.1:1234",
+			wantStatus:    http.StatusOK,
+			wantPackage:   "/foo",
+			wantSubpath:   "bar",
+			wantRemoteIP:  "192.0.2.1",
+			wantGoGetFlag: true,
+		},
+		{
+			name:         "not_found",
+			target:       "/baz",
+			remoteAddr:   "192.0.2.1:1234",
+			wantStatus:   http.StatusNotFound,
+			wantRemoteIP: "192.0.2.1",
+		},
+		{
+			name:         "untrusted_forwarded_for",
+			target:       "/foo",
+			remoteAddr:   "192.0.2.1:1234",
+			forwardedFor: "198.51.100.1",
+			wantStatus:   http.StatusOK,
+			wantPackage:  "/foo",
+			wantRemoteIP: "192.0.2.1",
+		},
+		{
+			name:         "trusted_forwarded_for",
+			opts:         trusted,
+			target:       "/foo",
+			remoteAddr:   "10.0.0.1:1234",
+			forwardedFor: "198.51.100.2, 198.51.100.1, 10.0.0.2",
+			wantStatus:   http.StatusOK,
+			wantPackage:  "/foo",
+			wantRemoteIP: "198.51.100.1",
+		},
+		{
+			name:         "unix_socket_forwarded_for",
+			target:       "/foo",
+			remoteAddr:   "@",
+			forwardedFor: "198.51.100.2, 198.51.100.1",
+			wantStatus:   http.StatusOK,
+			wantPackage:  "/foo",
+			wantRemoteIP: "198.51.100.1",
+		},
+		{
+			name:         "trusted_forwarded",
+			opts:         trusted,
+			target:       "/foo",
+			remoteAddr:   "10.0.0.1:1234",
+			forwarded:    `for=198.51.100.2, for="[2001:db8::1]:4711";proto=https, for=10.0.0.2`,
+			forwardedFor: "198.51.100.3",
+			wantStatus:   http.StatusOK,
+			wantPackage:  "/foo",
+			wantRemoteIP: "2001:db8::1",
+		},
+		{
+			name:         "trusted_forwarded_obfuscated",
+			opts:         trusted,
+			target:       "/foo",
+			remoteAddr:   "10.0.0.1:1234",
+			forwarded:    `for=198.51.100.2, for=_hidden`,
+			wantStatus:   http.StatusOK,
+			wantPackage:  "/foo",
+			wantRemoteIP: "10.0.0.1",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			buf := bytes.NewBuffer(nil)
+			handler := vanityurl.NewAccessLogHandler(srv, slog.New(slog.NewJSONHandler(buf, nil)), tc.opts)
+
+			req := httptest.NewRequest(http.MethodGet, tc.target, nil)
+			req.RemoteAddr = tc.remoteAddr
+			req.Header.Set("User-Agent", "Go-http-client/1.1")
+
+			if tc.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tc.forwardedFor)
+			}
+
+			if tc.forwarded != "" {
+				req.Header.Set("Forwarded", tc.forwarded)
+			}
+
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			var record map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+				t.Fatalf("got error while decoding log record %s: %v", buf.String(), err)
+			}
+
+			want := map[string]any{
+				"msg":        "Request",
+				"method":     http.MethodGet,
+				"host":       "example.com",
+				"status":     tc.wantStatus,
+				"package":    tc.wantPackage,
+				"subpath":    tc.wantSubpath,
+				"user_agent": "Go-http-client/1.1",
+				"remote_ip":  tc.wantRemoteIP,
+				"go_get":     tc.wantGoGetFlag,
+			}
+
+			for key, value := range want {
+				if record[key] != value {
+					t.Errorf("AccessLog record[%s] = %v; want = %v", key, record[key], value)
+				}
+			}
+
+			if size, _ := record["bytes"].(float64); tc.wantStatus == http.StatusOK && size == 0 {
+				t.Errorf("AccessLog record[bytes] = %v; want > 0", record["bytes"])
+			}
+		})
+	}
+}
@@ -0,0 +1,258 @@
+// Package auth authorizes requests to private [vanityurl.Package] with HTTP basic auth users
+// and bearer tokens. It is kept apart from package vanityurl, so the library does not depend
+// on golang.org/x/crypto unless authorization is used:
+//
+//	a, err := auth.New(auth.Options{Users: users})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//
+//	srv := vanityurl.NewServer(resolver, &vanityurl.ServerOptions{Authorizer: a})
+package auth
+
+import (
+	"bufio"
+	"cmp"
+	"crypto/sha1" //nolint:gosec
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"go.wamod.dev/vanityurl"
+)
+
+const (
+	htpasswdSHAPrefix = "{SHA}"
+	defaultAuthRealm  = "vanityurl"
+)
+
+// Options for [New].
+type Options struct {
+	// Users with htpasswd password hashes by name. Supported are bcrypt and {SHA} hashes.
+	Users map[string]string
+	// Tokens accepted as "Authorization: Bearer" credentials.
+	Tokens []Token
+	// Realm reported in WWW-Authenticate header. Default is "vanityurl".
+	Realm string
+	// Stealth answers unauthorized requests as not found, so private paths are not revealed.
+	Stealth bool
+}
+
+// Token is a static bearer token scoped to path prefixes.
+type Token struct {
+	// Name identifies token in logs instead of its value.
+	Name  string
+	Token string
+	// Prefixes of package paths token gives access to, e.g. "/internal". All packages if empty.
+	Prefixes []string
+}
+
+// allows reports whether token gives access to a package path.
+func (t Token) allows(path string) bool {
+	if len(t.Prefixes) == 0 {
+		return true
+	}
+
+	for _, prefix := range t.Prefixes {
+		if prefix == "/" || path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Auth is a [vanityurl.Authorizer] and [vanityurl.Challenger] checking HTTP basic auth users and bearer tokens.
+// Both are sent by the go tool from .netrc or GOAUTH.
+type Auth struct {
+	users   map[string]string
+	tokens  []Token
+	realm   string
+	stealth bool
+
+	// verified keeps digest of the last password matching bcrypt hash of each user, so repeated
+	// requests are not slowed down by bcrypt. It holds at most one entry per user.
+	mu       sync.Mutex
+	verified map[string][sha256.Size]byte
+}
+
+// New creates [Auth] from options. Returns [vanityurl.ErrInvalidAuth] if a password hash
+// is not supported or a token is empty.
+func New(opts Options) (*Auth, error) {
+	auth := &Auth{
+		users:    make(map[string]string, len(opts.Users)),
+		tokens:   make([]Token, len(opts.Tokens)),
+		realm:    opts.Realm,
+		stealth:  opts.Stealth,
+		verified: map[string][sha256.Size]byte{},
+	}
+
+	if auth.realm == "" {
+		auth.realm = defaultAuthRealm
+	}
+
+	for name, hash := range opts.Users {
+		if name == "" || strings.Contains(name, ":") {
+			return nil, fmt.Errorf("%w: invalid user name: %q", vanityurl.ErrInvalidAuth, name)
+		} else if !strings.HasPrefix(hash, htpasswdSHAPrefix) {
+			if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+				return nil, fmt.Errorf("%w: unsupported password hash of user %s: %w", vanityurl.ErrInvalidAuth, name, err)
+			}
+		}
+
+		auth.users[name] = hash
+	}
+
+	for i, token := range opts.Tokens {
+		if token.Token == "" {
+			return nil, fmt.Errorf("%w: empty token: %s", vanityurl.ErrInvalidAuth, token.Name)
+		}
+
+		token.Prefixes = append([]string(nil), token.Prefixes...)
+		for j, prefix := range token.Prefixes {
+			token.Prefixes[j] = cleanPrefix(prefix)
+		}
+
+		auth.tokens[i] = token
+	}
+
+	return auth, nil
+}
+
+// Authorize implements [vanityurl.Authorizer]. Users are allowed to access all private packages,
+// and tokens only packages with canonical path within their prefixes, so all aliases of a package
+// share the same scope.
+func (auth *Auth) Authorize(r *http.Request, pkg vanityurl.Package) bool {
+	if user, password, ok := r.BasicAuth(); ok {
+		return auth.checkUser(user, password)
+	}
+
+	if i, ok := auth.token(r); ok {
+		return auth.tokens[i].allows(pkg.CanonicalPath())
+	}
+
+	return false
+}
+
+// Identify returns "user:<name>" or "token:<name>" for valid credentials, or empty string otherwise.
+// It can be used as [vanityurl.RateLimitOptions.Identify].
+func (auth *Auth) Identify(r *http.Request) string {
+	if user, password, ok := r.BasicAuth(); ok {
+		if auth.checkUser(user, password) {
+			return "user:" + user
+		}
+
+		return ""
+	}
+
+	if i, ok := auth.token(r); ok {
+		return "token:" + cmp.Or(auth.tokens[i].Name, strconv.Itoa(i))
+	}
+
+	return ""
+}
+
+// Challenge implements [vanityurl.Challenger]. Returns empty string in stealth mode.
+func (auth *Auth) Challenge(_ *http.Request, _ vanityurl.Package) string {
+	if auth.stealth {
+		return ""
+	}
+
+	return fmt.Sprintf("Basic realm=%q, Bearer realm=%q", auth.realm, auth.realm)
+}
+
+// token returns index of bearer token sent with request.
+func (auth *Auth) token(r *http.Request) (int, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return 0, false
+	}
+
+	for i, t := range auth.tokens {
+		if subtle.ConstantTimeCompare([]byte(t.Token), []byte(token)) == 1 {
+			return i, true
+		}
+	}
+
+	return 0, false
+}
+
+// checkUser verifies password of user. Passwords matching bcrypt hash are cached.
+func (auth *Auth) checkUser(user, password string) bool {
+	hash, ok := auth.users[user]
+	if !ok {
+		return false
+	}
+
+	if sum, ok := strings.CutPrefix(hash, htpasswdSHAPrefix); ok {
+		digest := sha1.Sum([]byte(password)) //nolint:gosec
+
+		return subtle.ConstantTimeCompare([]byte(sum), []byte(base64.StdEncoding.EncodeToString(digest[:]))) == 1
+	}
+
+	digest := sha256.Sum256([]byte(password))
+
+	auth.mu.Lock()
+	cached, ok := auth.verified[user]
+	auth.mu.Unlock()
+
+	if ok && subtle.ConstantTimeCompare(cached[:], digest[:]) == 1 {
+		return true
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return false
+	}
+
+	auth.mu.Lock()
+	auth.verified[user] = digest
+	auth.mu.Unlock()
+
+	return true
+}
+
+// ParseHtpasswd reads "user:hash" lines of htpasswd file. Empty lines and lines starting with '#' are skipped.
+func ParseHtpasswd(rd io.Reader) (map[string]string, error) {
+	users := map[string]string{}
+	scanner := bufio.NewScanner(rd)
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		user, hash, ok := strings.Cut(text, ":")
+		if !ok {
+			return nil, fmt.Errorf("%w: htpasswd line %d: missing ':'", vanityurl.ErrInvalidAuth, line)
+		}
+
+		users[user] = hash
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// cleanPrefix trims spaces and trailing slash, and adds leading slash.
+func cleanPrefix(prefix string) string {
+	prefix = strings.TrimSpace(prefix)
+	prefix = strings.TrimSuffix(prefix, "/")
+
+	if !strings.HasPrefix(prefix, "/") {
+		prefix = "/" + prefix
+	}
+
+	return prefix
+}
@@ -0,0 +1,135 @@
+package auth_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"go.wamod.dev/vanityurl"
+	"go.wamod.dev/vanityurl/auth"
+)
+
+func TestAuth(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("alice-secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("failed to hash password: %v", err)
+	}
+
+	authorizer, err := auth.New(auth.Options{
+		Users: map[string]string{
+			"alice": string(hash),
+			// htpasswd -s bob bob-secret
+			"bob": "{SHA}Md7yGSbrVBY29morDdFNHvcmrxg=",
+		},
+		Tokens: []auth.Token{
+			{Name: "ci", Token: "ci-token", Prefixes: []string{"/internal/"}},
+			{Name: "x", Token: "x-token", Prefixes: []string{"/x"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("New() = %v", err)
+	}
+
+	tt := []struct {
+		name      string
+		path      string
+		canonical string
+		setup     func(r *http.Request)
+		want      bool
+	}{
+		{name: "anonymous", path: "/internal/foo", setup: func(*http.Request) {}, want: false},
+		{name: "bcrypt_user", path: "/internal/foo", setup: func(r *http.Request) { r.SetBasicAuth("alice", "alice-secret") }, want: true},
+		{name: "sha_user", path: "/other", setup: func(r *http.Request) { r.SetBasicAuth("bob", "bob-secret") }, want: true},
+		{name: "wrong_password", path: "/internal/foo", setup: func(r *http.Request) { r.SetBasicAuth("alice", "bob-secret") }, want: false},
+		{name: "unknown_user", path: "/internal/foo", setup: func(r *http.Request) { r.SetBasicAuth("carol", "alice-secret") }, want: false},
+		{name: "token_in_prefix", path: "/internal/foo", setup: func(r *http.Request) { r.Header.Set("Authorization", "Bearer ci-token") }, want: true},
+		{name: "token_prefix_itself", path: "/internal", setup: func(r *http.Request) { r.Header.Set("Authorization", "Bearer ci-token") }, want: true},
+		{name: "token_out_of_prefix", path: "/internalx", setup: func(r *http.Request) { r.Header.Set("Authorization", "Bearer ci-token") }, want: false},
+		{name: "token_alias_of_prefix", path: "/x/internal", canonical: "/internal", setup: func(r *http.Request) { r.Header.Set("Authorization", "Bearer ci-token") }, want: true},
+		{name: "token_prefix_of_alias", path: "/internal", canonical: "/internal", setup: func(r *http.Request) { r.Header.Set("Authorization", "Bearer x-token") }, want: false},
+		{name: "token_alias_in_prefix", path: "/x/internal", canonical: "/internal", setup: func(r *http.Request) { r.Header.Set("Authorization", "Bearer x-token") }, want: false},
+		{name: "unknown_token", path: "/internal/foo", setup: func(r *http.Request) { r.Header.Set("Authorization", "Bearer other") }, want: false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tc.path+"?go-get=1", nil)
+			tc.setup(r)
+
+			if got := authorizer.Authorize(r, vanityurl.Package{Path: tc.path, Canonical: tc.canonical}); got != tc.want {
+				t.Errorf("Auth.Authorize() = %t; want = %t", got, tc.want)
+			}
+		})
+	}
+
+	for _, tc := range []struct {
+		setup func(r *http.Request)
+		want  string
+	}{
+		{setup: func(*http.Request) {}, want: ""},
+		{setup: func(r *http.Request) { r.SetBasicAuth("alice", "alice-secret") }, want: "user:alice"},
+		{setup: func(r *http.Request) { r.SetBasicAuth("alice", "wrong") }, want: ""},
+		{setup: func(r *http.Request) { r.Header.Set("Authorization", "Bearer ci-token") }, want: "token:ci"},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/internal/foo", nil)
+		tc.setup(r)
+
+		if got := authorizer.Identify(r); got != tc.want {
+			t.Errorf("Auth.Identify() = %q; want = %q", got, tc.want)
+		}
+	}
+
+	if got, want := authorizer.Challenge(nil, vanityurl.Package{}), `Basic realm="vanityurl", Bearer realm="vanityurl"`; got != want {
+		t.Errorf("Auth.Challenge() = %s; want = %s", got, want)
+	}
+}
+
+func TestNew(t *testing.T) {
+	tt := []struct {
+		name    string
+		opts    auth.Options
+		wantErr bool
+	}{
+		{name: "empty", opts: auth.Options{}},
+		{name: "apr1_hash", opts: auth.Options{Users: map[string]string{"alice": "$apr1$abc$def"}}, wantErr: true},
+		{name: "plain_password", opts: auth.Options{Users: map[string]string{"alice": "secret"}}, wantErr: true},
+		{name: "empty_token", opts: auth.Options{Tokens: []auth.Token{{Name: "ci"}}}, wantErr: true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := auth.New(tc.opts); tc.wantErr != (err != nil) {
+				t.Errorf("New() = %v; wantErr = %v", err, tc.wantErr)
+			}
+		})
+	}
+
+	stealth, err := auth.New(auth.Options{Stealth: true})
+	if err != nil {
+		t.Fatalf("New() = %v", err)
+	}
+
+	if got := stealth.Challenge(nil, vanityurl.Package{}); got != "" {
+		t.Errorf("Auth.Challenge() = %s; want empty in stealth mode", got)
+	}
+}
+
+func TestParseHtpasswd(t *testing.T) {
+	got, err := auth.ParseHtpasswd(strings.NewReader("# users\nalice:$2y$05$abc\n\nbob:{SHA}xyz\n"))
+	if err != nil {
+		t.Fatalf("ParseHtpasswd() = %v", err)
+	}
+
+	want := map[string]string{"alice": "$2y$05$abc", "bob": "{SHA}xyz"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseHtpasswd() = %v; want = %v", got, want)
+	}
+
+	if _, err := auth.ParseHtpasswd(strings.NewReader("alice\n")); err == nil {
+		t.Errorf("ParseHtpasswd() = nil; want error for line without ':'")
+	}
+}
@@ -0,0 +1,124 @@
+// Package awslambda serves [vanityurl.Server] from AWS Lambda behind API Gateway or Function URLs.
+//
+// [Handler] translates API Gateway REST API (payload format 1.0), HTTP API (payload format 2.0)
+// and Function URL events into HTTP requests and back into responses. It implements Invoke method
+// of the lambda.Handler interface from github.com/aws/aws-lambda-go, so this package does not
+// depend on AWS libraries:
+//
+//	//go:embed vanityurl.yml
+//	var config []byte
+//
+//	func main() {
+//		cfg, err := awslambda.LoadConfig(config)
+//		if err != nil {
+//			log.Fatal(err)
+//		}
+//
+//		srv, err := cfg.NewServer()
+//		if err != nil {
+//			log.Fatal(err)
+//		}
+//
+//		lambda.StartHandler(awslambda.NewHandler(srv))
+//	}
+package awslambda
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Handler of Lambda invocations with API Gateway or Function URL events.
+type Handler struct {
+	handler http.Handler
+}
+
+// NewHandler creates Lambda handler serving requests with a given handler, usually [vanityurl.Server].
+func NewHandler(handler http.Handler) *Handler {
+	return &Handler{
+		handler: handler,
+	}
+}
+
+// Invoke handles JSON event payload and returns JSON response in the same payload format.
+func (h *Handler) Invoke(ctx context.Context, payload []byte) ([]byte, error) {
+	var probe struct {
+		Version    string `json:"version"`
+		HTTPMethod string `json:"httpMethod"`
+	}
+
+	if err := json.Unmarshal(payload, &probe); err != nil {
+		return nil, fmt.Errorf("awslambda: invalid event: %w", err)
+	}
+
+	switch {
+	case probe.Version == payloadVersion2:
+		var event eventV2
+
+		if err := json.Unmarshal(payload, &event); err != nil {
+			return nil, fmt.Errorf("awslambda: invalid event: %w", err)
+		}
+
+		r, err := event.Request(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("awslambda: invalid request: %w", err)
+		}
+
+		return h.serve(r).ResponseV2()
+	case probe.HTTPMethod != "":
+		var event eventV1
+
+		if err := json.Unmarshal(payload, &event); err != nil {
+			return nil, fmt.Errorf("awslambda: invalid event: %w", err)
+		}
+
+		r, err := event.Request(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("awslambda: invalid request: %w", err)
+		}
+
+		return h.serve(r).ResponseV1()
+	default:
+		return nil, fmt.Errorf("awslambda: unsupported event payload version %q", probe.Version)
+	}
+}
+
+func (h *Handler) serve(r *http.Request) *responseWriter {
+	w := &responseWriter{
+		header: http.Header{},
+	}
+
+	h.handler.ServeHTTP(w, r)
+
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+
+	return w
+}
+
+// responseWriter buffers response to be encoded as Lambda response.
+type responseWriter struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func (w *responseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+
+	return w.body.Write(b)
+}
@@ -0,0 +1,221 @@
+package awslambda_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.wamod.dev/vanityurl/awslambda"
+)
+
+const testConfig = `host: go.example.com
+cache_age: 1h
+packages:
+  - path: /foo
+    repository_url: https://github.com/example/foo
+`
+
+func TestHandlerInvoke(t *testing.T) {
+	cfg, err := awslambda.LoadConfig([]byte(testConfig))
+	if err != nil {
+		t.Fatalf("LoadConfig() = %v", err)
+	}
+
+	srv, err := cfg.NewServer()
+	if err != nil {
+		t.Fatalf("NewServer() = %v", err)
+	}
+
+	handler := awslambda.NewHandler(srv)
+
+	tt := []struct {
+		name       string
+		event      string
+		target     string
+		wantStatus int
+		wantV2     bool
+	}{
+		{
+			name:       "api_gateway_v1",
+			event:      "apigw_v1.json",
+			target:     "/foo/bar?go-get=1",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "api_gateway_v1_not_found",
+			event:      "apigw_v1_not_found.json",
+			target:     "/missing",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "api_gateway_v2",
+			event:      "apigw_v2.json",
+			target:     "/foo/bar?go-get=1",
+			wantStatus: http.StatusOK,
+			wantV2:     true,
+		},
+		{
+			name:       "function_url",
+			event:      "function_url.json",
+			target:     "/foo",
+			wantStatus: http.StatusOK,
+			wantV2:     true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			payload, err := os.ReadFile(filepath.Join("testdata", tc.event))
+			if err != nil {
+				t.Fatalf("failed to read event: %v", err)
+			}
+
+			got, err := handler.Invoke(context.Background(), payload)
+			if err != nil {
+				t.Fatalf("Invoke() = %v", err)
+			}
+
+			want := httptest.NewRecorder()
+			srv.ServeHTTP(want, httptest.NewRequest(http.MethodGet, tc.target, nil))
+
+			var resp struct {
+				StatusCode        int                 `json:"statusCode"`
+				Headers           map[string]string   `json:"headers"`
+				MultiValueHeaders map[string][]string `json:"multiValueHeaders"`
+				Body              string              `json:"body"`
+				IsBase64Encoded   bool                `json:"isBase64Encoded"`
+			}
+
+			if err := json.Unmarshal(got, &resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+
+			if resp.StatusCode != tc.wantStatus {
+				t.Errorf("statusCode = %d, want %d", resp.StatusCode, tc.wantStatus)
+			}
+
+			if resp.Body != want.Body.String() {
+				t.Errorf("body = %q, want %q", resp.Body, want.Body.String())
+			}
+
+			if resp.IsBase64Encoded {
+				t.Errorf("isBase64Encoded = true, want false")
+			}
+
+			contentType := want.Header().Get("Content-Type")
+
+			if tc.wantV2 {
+				if resp.Headers["Content-Type"] != contentType {
+					t.Errorf("headers[Content-Type] = %q, want %q", resp.Headers["Content-Type"], contentType)
+				}
+
+				if resp.MultiValueHeaders != nil {
+					t.Errorf("multiValueHeaders = %v, want nil", resp.MultiValueHeaders)
+				}
+			} else if values := resp.MultiValueHeaders["Content-Type"]; len(values) != 1 || values[0] != contentType {
+				t.Errorf("multiValueHeaders[Content-Type] = %v, want [%q]", values, contentType)
+			}
+		})
+	}
+}
+
+func TestHandlerInvokeRequest(t *testing.T) {
+	tt := []struct {
+		name           string
+		event          string
+		wantHost       string
+		wantRemoteAddr string
+		wantCookie     string
+		wantPath       string
+		wantRequestURI string
+	}{
+		{
+			name:           "api_gateway_v1",
+			event:          "apigw_v1.json",
+			wantPath:       "/foo/bar",
+			wantRequestURI: "/foo/bar?go-get=1",
+			wantHost:       "go.example.com",
+			wantRemoteAddr: "198.51.100.7:0",
+		},
+		{
+			name:           "api_gateway_v2_encoded",
+			event:          "apigw_v2_encoded.json",
+			wantPath:       "/foo/bar baz",
+			wantRequestURI: "/foo/bar%20baz?go-get=1&tag=a%26b",
+			wantHost:       "go.example.com",
+			wantRemoteAddr: "198.51.100.7:0",
+		},
+		{
+			name:           "function_url",
+			event:          "function_url.json",
+			wantPath:       "/foo",
+			wantRequestURI: "/foo",
+			wantHost:       "abcdefghijklmnopqrstuvwxyz012345.lambda-url.eu-central-1.on.aws",
+			wantRemoteAddr: "203.0.113.21:0",
+			wantCookie:     "theme=dark",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			payload, err := os.ReadFile(filepath.Join("testdata", tc.event))
+			if err != nil {
+				t.Fatalf("failed to read event: %v", err)
+			}
+
+			var got *http.Request
+
+			handler := awslambda.NewHandler(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				got = r
+			}))
+
+			if _, err := handler.Invoke(context.Background(), payload); err != nil {
+				t.Fatalf("Invoke() = %v", err)
+			}
+
+			if got.URL.Path != tc.wantPath {
+				t.Errorf("URL.Path = %q, want %q", got.URL.Path, tc.wantPath)
+			}
+
+			if got.RequestURI != tc.wantRequestURI {
+				t.Errorf("RequestURI = %q, want %q", got.RequestURI, tc.wantRequestURI)
+			}
+
+			if got.Host != tc.wantHost {
+				t.Errorf("Host = %q, want %q", got.Host, tc.wantHost)
+			}
+
+			if got.RemoteAddr != tc.wantRemoteAddr {
+				t.Errorf("RemoteAddr = %q, want %q", got.RemoteAddr, tc.wantRemoteAddr)
+			}
+
+			if cookie := got.Header.Get("Cookie"); cookie != tc.wantCookie {
+				t.Errorf("Cookie = %q, want %q", cookie, tc.wantCookie)
+			}
+		})
+	}
+}
+
+func TestHandlerInvokeInvalid(t *testing.T) {
+	handler := awslambda.NewHandler(http.NotFoundHandler())
+
+	unsupported, err := os.ReadFile(filepath.Join("testdata", "unsupported.json"))
+	if err != nil {
+		t.Fatalf("failed to read event: %v", err)
+	}
+
+	for name, payload := range map[string][]byte{
+		"not_json":    []byte("{"),
+		"unsupported": unsupported,
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := handler.Invoke(context.Background(), payload); err == nil {
+				t.Errorf("Invoke() = nil, want error")
+			}
+		})
+	}
+}
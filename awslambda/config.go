@@ -0,0 +1,108 @@
+package awslambda
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"go.wamod.dev/vanityurl"
+)
+
+const (
+	envConfigYAML = "VANITYURL_CONFIG_YAML"
+	envHost       = "VANITYURL_HOST"
+	envCacheAge   = "VANITYURL_CACHE_AGE"
+
+	defaultCacheAge = 24 * time.Hour
+)
+
+// Config of the served packages. It uses the same YAML keys as the vanityurl command.
+type Config struct {
+	Host     string          `yaml:"host"`
+	CacheAge time.Duration   `yaml:"cache_age"`
+	Packages []PackageConfig `yaml:"packages"`
+	// NotFoundCacheAge for 404 responses, not cached if zero.
+	NotFoundCacheAge time.Duration `yaml:"not_found_cache_age"`
+	// TemplatesDir with custom templates bundled with the function, see [vanityurl.ParseTemplatesFS].
+	TemplatesDir string `yaml:"templates_dir"`
+}
+
+// PackageConfig is a single package entry of [Config].
+type PackageConfig = vanityurl.PackageConfig
+
+// LoadConfig parses YAML config, usually embedded into the binary, and applies environment overrides:
+//
+//   - VANITYURL_CONFIG_YAML replaces the whole YAML config;
+//   - VANITYURL_HOST overrides host;
+//   - VANITYURL_CACHE_AGE overrides cache age, e.g. "1h".
+//
+// Data may be empty when the config is given by environment only.
+func LoadConfig(data []byte) (Config, error) {
+	if raw, ok := os.LookupEnv(envConfigYAML); ok {
+		data = []byte(raw)
+	}
+
+	var cfg Config
+
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return Config{}, fmt.Errorf("awslambda: failed to parse config: %w", err)
+	}
+
+	if host, ok := os.LookupEnv(envHost); ok {
+		cfg.Host = host
+	}
+
+	if raw, ok := os.LookupEnv(envCacheAge); ok {
+		cacheAge, err := time.ParseDuration(raw)
+		if err != nil {
+			return Config{}, fmt.Errorf("awslambda: invalid %s: %w", envCacheAge, err)
+		}
+
+		cfg.CacheAge = cacheAge
+	}
+
+	if cfg.CacheAge == 0 {
+		cfg.CacheAge = defaultCacheAge
+	}
+
+	return cfg, nil
+}
+
+// NewServer creates [vanityurl.Server] with a static resolver of configured packages.
+// If host is not set, host of each request is used.
+func (cfg Config) NewServer() (*vanityurl.Server, error) {
+	packages := make([]vanityurl.Package, len(cfg.Packages))
+
+	for i, pkgCfg := range cfg.Packages {
+		pkg, err := pkgCfg.Package()
+		if err != nil {
+			return nil, err
+		}
+
+		packages[i] = pkg
+	}
+
+	resolver, err := vanityurl.NewResolver(packages...)
+	if err != nil {
+		return nil, err
+	}
+
+	templates := vanityurl.DefaultTemplates()
+
+	if cfg.TemplatesDir != "" {
+		templates, err = vanityurl.ParseTemplatesFS(os.DirFS(cfg.TemplatesDir))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return vanityurl.NewServer(resolver, &vanityurl.ServerOptions{
+		Host:      cfg.Host,
+		CacheAge:  cfg.CacheAge,
+		Templates: templates,
+
+		NotFoundCacheAge: cfg.NotFoundCacheAge,
+	}), nil
+}
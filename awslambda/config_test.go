@@ -0,0 +1,137 @@
+package awslambda_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.wamod.dev/vanityurl/awslambda"
+)
+
+func TestLoadConfig(t *testing.T) {
+	tt := []struct {
+		name    string
+		data    string
+		env     map[string]string
+		want    awslambda.Config
+		wantErr bool
+	}{
+		{
+			name: "embedded",
+			data: testConfig,
+			want: awslambda.Config{
+				Host:     "go.example.com",
+				CacheAge: time.Hour,
+				Packages: []awslambda.PackageConfig{
+					{Path: "/foo", RepositoryURL: "https://github.com/example/foo"},
+				},
+			},
+		},
+		{
+			name: "env_override",
+			data: testConfig,
+			env: map[string]string{
+				"VANITYURL_HOST":      "go.example.org",
+				"VANITYURL_CACHE_AGE": "5m",
+			},
+			want: awslambda.Config{
+				Host:     "go.example.org",
+				CacheAge: 5 * time.Minute,
+				Packages: []awslambda.PackageConfig{
+					{Path: "/foo", RepositoryURL: "https://github.com/example/foo"},
+				},
+			},
+		},
+		{
+			name: "env_yaml",
+			env: map[string]string{
+				"VANITYURL_CONFIG_YAML": "packages:\n  - path: /bar\n    vcs: hg\n    repository_url: https://example.org/bar\n",
+			},
+			want: awslambda.Config{
+				CacheAge: 24 * time.Hour,
+				Packages: []awslambda.PackageConfig{
+					{Path: "/bar", VCS: "hg", RepositoryURL: "https://example.org/bar"},
+				},
+			},
+		},
+		{
+			name: "scheduled",
+			data: "packages:\n  - path: /next\n    repository_url: https://github.com/example/next\n    publish_at: 2024-06-01T10:00:00Z\n",
+			want: awslambda.Config{
+				CacheAge: 24 * time.Hour,
+				Packages: []awslambda.PackageConfig{
+					{
+						Path:          "/next",
+						RepositoryURL: "https://github.com/example/next",
+						PublishAt:     time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC),
+					},
+				},
+			},
+		},
+		{
+			name:    "invalid_yaml",
+			data:    "packages: {",
+			wantErr: true,
+		},
+		{
+			name: "invalid_cache_age",
+			env: map[string]string{
+				"VANITYURL_CACHE_AGE": "soon",
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			for key, value := range tc.env {
+				t.Setenv(key, value)
+			}
+
+			got, err := awslambda.LoadConfig([]byte(tc.data))
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("LoadConfig() error = %v, wantErr %v", err, tc.wantErr)
+			} else if err != nil {
+				return
+			}
+
+			if got.Host != tc.want.Host || got.CacheAge != tc.want.CacheAge || len(got.Packages) != len(tc.want.Packages) {
+				t.Fatalf("LoadConfig() = %+v, want %+v", got, tc.want)
+			}
+
+			for i := range got.Packages {
+				if !reflect.DeepEqual(got.Packages[i], tc.want.Packages[i]) {
+					t.Errorf("LoadConfig().Packages[%d] = %+v, want %+v", i, got.Packages[i], tc.want.Packages[i])
+				}
+			}
+		})
+	}
+}
+
+func TestConfigNewServer(t *testing.T) {
+	cfg := awslambda.Config{
+		Packages: []awslambda.PackageConfig{
+			{Path: "/foo", VCS: "cvs", RepositoryURL: "https://example.org/foo"},
+		},
+	}
+
+	if _, err := cfg.NewServer(); err == nil {
+		t.Errorf("NewServer() = nil, want error for invalid vcs")
+	}
+
+	cfg.Packages[0].VCS = "git"
+
+	srv, err := cfg.NewServer()
+	if err != nil {
+		t.Fatalf("NewServer() = %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/foo?go-get=1", nil))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("ServeHTTP() status = %d, want %d", w.Code, http.StatusOK)
+	}
+}
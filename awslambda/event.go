@@ -0,0 +1,221 @@
+package awslambda
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+const payloadVersion2 = "2.0"
+
+// eventV1 is API Gateway REST API proxy event, payload format 1.0.
+type eventV1 struct {
+	Version                         string              `json:"version"`
+	HTTPMethod                      string              `json:"httpMethod"`
+	Path                            string              `json:"path"`
+	Headers                         map[string]string   `json:"headers"`
+	MultiValueHeaders               map[string][]string `json:"multiValueHeaders"`
+	QueryStringParameters           map[string]string   `json:"queryStringParameters"`
+	MultiValueQueryStringParameters map[string][]string `json:"multiValueQueryStringParameters"`
+	Body                            string              `json:"body"`
+	IsBase64Encoded                 bool                `json:"isBase64Encoded"`
+	RequestContext                  struct {
+		DomainName string `json:"domainName"`
+		Identity   struct {
+			SourceIP string `json:"sourceIp"`
+		} `json:"identity"`
+	} `json:"requestContext"`
+}
+
+// responseV1 for payload format 1.0.
+type responseV1 struct {
+	StatusCode        int                 `json:"statusCode"`
+	Headers           map[string]string   `json:"headers,omitempty"`
+	MultiValueHeaders map[string][]string `json:"multiValueHeaders,omitempty"`
+	Body              string              `json:"body"`
+	IsBase64Encoded   bool                `json:"isBase64Encoded"`
+}
+
+// eventV2 is API Gateway HTTP API or Lambda Function URL event, payload format 2.0.
+type eventV2 struct {
+	Version         string            `json:"version"`
+	RawPath         string            `json:"rawPath"`
+	RawQueryString  string            `json:"rawQueryString"`
+	Cookies         []string          `json:"cookies"`
+	Headers         map[string]string `json:"headers"`
+	Body            string            `json:"body"`
+	IsBase64Encoded bool              `json:"isBase64Encoded"`
+	RequestContext  struct {
+		DomainName string `json:"domainName"`
+		HTTP       struct {
+			Method   string `json:"method"`
+			Path     string `json:"path"`
+			SourceIP string `json:"sourceIp"`
+		} `json:"http"`
+	} `json:"requestContext"`
+}
+
+// responseV2 for payload format 2.0.
+type responseV2 struct {
+	StatusCode      int               `json:"statusCode"`
+	Headers         map[string]string `json:"headers,omitempty"`
+	Cookies         []string          `json:"cookies,omitempty"`
+	Body            string            `json:"body"`
+	IsBase64Encoded bool              `json:"isBase64Encoded"`
+}
+
+func (e eventV1) Request(ctx context.Context) (*http.Request, error) {
+	query := url.Values{}
+
+	for key, value := range e.QueryStringParameters {
+		query.Set(key, value)
+	}
+
+	for key, values := range e.MultiValueQueryStringParameters {
+		query[key] = values
+	}
+
+	header := http.Header{}
+
+	for key, value := range e.Headers {
+		header.Set(key, value)
+	}
+
+	for key, values := range e.MultiValueHeaders {
+		header.Del(key)
+
+		for _, value := range values {
+			header.Add(key, value)
+		}
+	}
+
+	// Path of payload format 1.0 is decoded, so it is escaped again
+	target := (&url.URL{Path: e.Path, RawQuery: query.Encode()}).RequestURI()
+
+	return newRequest(ctx, requestParams{
+		method:   e.HTTPMethod,
+		target:   target,
+		header:   header,
+		host:     e.RequestContext.DomainName,
+		sourceIP: e.RequestContext.Identity.SourceIP,
+		body:     e.Body,
+		base64:   e.IsBase64Encoded,
+	})
+}
+
+func (e eventV2) Request(ctx context.Context) (*http.Request, error) {
+	header := http.Header{}
+
+	for key, value := range e.Headers {
+		header.Set(key, value)
+	}
+
+	if len(e.Cookies) > 0 {
+		header.Set("Cookie", strings.Join(e.Cookies, "; "))
+	}
+
+	// Raw path and query of payload format 2.0 are already escaped as sent by client
+	target := e.RawPath
+	if e.RawQueryString != "" {
+		target += "?" + e.RawQueryString
+	}
+
+	return newRequest(ctx, requestParams{
+		method:   e.RequestContext.HTTP.Method,
+		target:   target,
+		header:   header,
+		host:     e.RequestContext.DomainName,
+		sourceIP: e.RequestContext.HTTP.SourceIP,
+		body:     e.Body,
+		base64:   e.IsBase64Encoded,
+	})
+}
+
+type requestParams struct {
+	method   string
+	target   string
+	header   http.Header
+	host     string
+	sourceIP string
+	body     string
+	base64   bool
+}
+
+func newRequest(ctx context.Context, params requestParams) (*http.Request, error) {
+	body := []byte(params.body)
+
+	if params.base64 {
+		decoded, err := base64.StdEncoding.DecodeString(params.body)
+		if err != nil {
+			return nil, err
+		}
+
+		body = decoded
+	}
+
+	r, err := http.NewRequestWithContext(ctx, params.method, params.target, strings.NewReader(string(body)))
+	if err != nil {
+		return nil, err
+	}
+
+	r.RequestURI = params.target
+	r.Header = params.header
+	r.Host = params.host
+
+	// Host header is moved to request field same as by http.Server
+	if host := params.header.Get("Host"); host != "" {
+		r.Host = host
+		params.header.Del("Host")
+	}
+
+	if params.sourceIP != "" {
+		r.RemoteAddr = net.JoinHostPort(params.sourceIP, "0")
+	}
+
+	return r, nil
+}
+
+// encodeBody as plain text when possible, otherwise as base64.
+func encodeBody(body []byte) (string, bool) {
+	if utf8.Valid(body) {
+		return string(body), false
+	}
+
+	return base64.StdEncoding.EncodeToString(body), true
+}
+
+func (w *responseWriter) ResponseV1() ([]byte, error) {
+	body, isBase64 := encodeBody(w.body.Bytes())
+
+	return json.Marshal(responseV1{
+		StatusCode:        w.code,
+		MultiValueHeaders: w.header,
+		Body:              body,
+		IsBase64Encoded:   isBase64,
+	})
+}
+
+func (w *responseWriter) ResponseV2() ([]byte, error) {
+	body, isBase64 := encodeBody(w.body.Bytes())
+
+	headers := make(map[string]string, len(w.header))
+
+	for key, values := range w.header {
+		if key != "Set-Cookie" {
+			headers[key] = strings.Join(values, ",")
+		}
+	}
+
+	return json.Marshal(responseV2{
+		StatusCode:      w.code,
+		Headers:         headers,
+		Cookies:         w.header.Values("Set-Cookie"),
+		Body:            body,
+		IsBase64Encoded: isBase64,
+	})
+}
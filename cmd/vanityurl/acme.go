@@ -0,0 +1,74 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+const (
+	acmeChallengeTLSALPN = "tls-alpn-01"
+	acmeChallengeHTTP    = "http-01"
+
+	defaultACMECacheDir = "acme-cache"
+)
+
+type yamlACME struct {
+	Enabled      bool     `yaml:"enabled"`
+	Hosts        []string `yaml:"hosts"`
+	Email        string   `yaml:"email"`
+	CacheDir     string   `yaml:"cache_dir"`
+	DirectoryURL string   `yaml:"directory_url"`
+	Challenge    string   `yaml:"challenge"`
+}
+
+func (a yamlACME) validate(redirectPort uint) error {
+	if !a.Enabled {
+		return nil
+	}
+
+	if len(a.Hosts) == 0 {
+		return errors.New("invalid tls acme config: hosts are required when host is not set")
+	}
+
+	switch a.Challenge {
+	case acmeChallengeTLSALPN:
+	case acmeChallengeHTTP:
+		if redirectPort == 0 {
+			return fmt.Errorf("invalid tls acme config: %s challenge requires redirect_port", acmeChallengeHTTP)
+		}
+	default:
+		return fmt.Errorf("invalid tls acme challenge %q: must be %s or %s", a.Challenge, acmeChallengeTLSALPN, acmeChallengeHTTP)
+	}
+
+	return nil
+}
+
+// newACMEManager obtains and renews certificates for configured hosts.
+func newACMEManager(cfg yamlACME) *autocert.Manager {
+	manager := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		Cache:      autocert.DirCache(cfg.CacheDir),
+		HostPolicy: autocert.HostWhitelist(cfg.Hosts...),
+		Email:      cfg.Email,
+	}
+
+	if cfg.DirectoryURL != "" {
+		manager.Client = &acme.Client{
+			DirectoryURL: cfg.DirectoryURL,
+		}
+	}
+
+	return manager
+}
+
+// newACMETLSConfig with certificates managed by ACME. Answers TLS-ALPN-01 challenges.
+func newACMETLSConfig(manager *autocert.Manager, minVersion uint16) *tls.Config {
+	tlsConfig := manager.TLSConfig()
+	tlsConfig.MinVersion = minVersion
+
+	return tlsConfig
+}
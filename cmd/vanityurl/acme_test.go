@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"golang.org/x/crypto/acme"
+)
+
+func Test_newACMEManager(t *testing.T) {
+	cfg := yamlACME{
+		Enabled:      true,
+		Hosts:        []string{"go.example.com"},
+		Email:        "ops@example.com",
+		CacheDir:     filepath.Join(t.TempDir(), "acme"),
+		DirectoryURL: "https://localhost:14000/dir",
+		Challenge:    acmeChallengeHTTP,
+	}
+
+	manager := newACMEManager(cfg)
+
+	if manager.Client == nil || manager.Client.DirectoryURL != cfg.DirectoryURL {
+		t.Errorf("newACMEManager() Client = %v; want DirectoryURL = %s", manager.Client, cfg.DirectoryURL)
+	}
+
+	if err := manager.HostPolicy(context.Background(), "go.example.com"); err != nil {
+		t.Errorf("Manager.HostPolicy(go.example.com) = %v; want nil", err)
+	}
+
+	if err := manager.HostPolicy(context.Background(), "other.example.com"); err == nil {
+		t.Errorf("Manager.HostPolicy(other.example.com) = nil; want error")
+	}
+
+	tlsConfig := newACMETLSConfig(manager, tls.VersionTLS13)
+
+	if tlsConfig.MinVersion != tls.VersionTLS13 {
+		t.Errorf("newACMETLSConfig() MinVersion = %d; want = %d", tlsConfig.MinVersion, tls.VersionTLS13)
+	}
+
+	if !slices.Contains(tlsConfig.NextProtos, acme.ALPNProto) {
+		t.Errorf("newACMETLSConfig() NextProtos = %v; want %s", tlsConfig.NextProtos, acme.ALPNProto)
+	}
+
+	handler := manager.HTTPHandler(redirectHandler(443))
+
+	tt := []struct {
+		name       string
+		target     string
+		wantStatus int
+	}{
+		{
+			name:       "unknown_challenge",
+			target:     "http://go.example.com/.well-known/acme-challenge/token",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "redirect",
+			target:     "http://go.example.com/foo",
+			wantStatus: http.StatusMovedPermanently,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.target, nil))
+
+			if rec.Code != tc.wantStatus {
+				t.Errorf("Manager.HTTPHandler() status = %d; want = %d", rec.Code, tc.wantStatus)
+			}
+		})
+	}
+}
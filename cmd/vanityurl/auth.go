@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"os"
+
+	"go.wamod.dev/vanityurl/auth"
+)
+
+type yamlAuth struct {
+	Realm   string `yaml:"realm"`
+	Stealth bool   `yaml:"stealth"`
+	// HtpasswdFile with bcrypt or {SHA} hashed users, merged with inline users.
+	HtpasswdFile string            `yaml:"htpasswd_file"`
+	Users        map[string]string `yaml:"users"`
+	Tokens       []yamlAuthToken   `yaml:"tokens"`
+}
+
+type yamlAuthToken struct {
+	Name     string   `yaml:"name"`
+	Token    string   `yaml:"token"`
+	Prefixes []string `yaml:"prefixes"`
+}
+
+// Enabled reports whether any credentials are configured.
+func (a yamlAuth) Enabled() bool {
+	return a.HtpasswdFile != "" || len(a.Users) > 0 || len(a.Tokens) > 0
+}
+
+// newAuth creates authorizer for private packages from config.
+func newAuth(cfg yamlAuth) (*auth.Auth, error) {
+	users := map[string]string{}
+
+	if cfg.HtpasswdFile != "" {
+		file, err := os.Open(cfg.HtpasswdFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open htpasswd file: %w", err)
+		}
+		defer file.Close()
+
+		users, err = auth.ParseHtpasswd(file)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	maps.Copy(users, cfg.Users)
+
+	tokens := make([]auth.Token, len(cfg.Tokens))
+	for i, token := range cfg.Tokens {
+		tokens[i] = auth.Token{
+			Name:     token.Name,
+			Token:    token.Token,
+			Prefixes: token.Prefixes,
+		}
+	}
+
+	return auth.New(auth.Options{
+		Users:   users,
+		Tokens:  tokens,
+		Realm:   cfg.Realm,
+		Stealth: cfg.Stealth,
+	})
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.wamod.dev/vanityurl"
+)
+
+func Test_newAuth(t *testing.T) {
+	tmpDir := t.TempDir()
+	htpasswd := filepath.Join(tmpDir, "htpasswd")
+
+	// htpasswd -s bob bob-secret
+	if err := os.WriteFile(htpasswd, []byte("bob:{SHA}Md7yGSbrVBY29morDdFNHvcmrxg=\n"), 0o600); err != nil {
+		t.Fatalf("failed to create htpasswd file: %v", err)
+	}
+
+	auth, err := newAuth(yamlAuth{
+		HtpasswdFile: htpasswd,
+		Tokens:       []yamlAuthToken{{Name: "ci", Token: "ci-token", Prefixes: []string{"/internal"}}},
+	})
+	if err != nil {
+		t.Fatalf("newAuth() = %v", err)
+	}
+
+	pkg := vanityurl.Package{Path: "/internal/foo", Visibility: vanityurl.Private}
+
+	r := httptest.NewRequest(http.MethodGet, "/internal/foo?go-get=1", nil)
+	r.SetBasicAuth("bob", "bob-secret")
+
+	if !auth.Authorize(r, pkg) {
+		t.Errorf("Auth.Authorize() = false; want htpasswd user allowed")
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/internal/foo?go-get=1", nil)
+	r.Header.Set("Authorization", "Bearer ci-token")
+
+	if !auth.Authorize(r, pkg) {
+		t.Errorf("Auth.Authorize() = false; want token allowed")
+	}
+
+	if _, err := newAuth(yamlAuth{HtpasswdFile: filepath.Join(tmpDir, "missing")}); err == nil {
+		t.Errorf("newAuth() = nil; want error for missing htpasswd file")
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/cgi"
+	"net/http/fcgi"
+	"os"
+	"sync"
+)
+
+const (
+	flagModeVar = "mode"
+
+	modeHTTP = "http"
+	modeCGI  = "cgi"
+	modeFCGI = "fcgi"
+
+	// envGatewayInterface is set by web servers for CGI programs.
+	envGatewayInterface = "GATEWAY_INTERFACE"
+)
+
+// detectMode returns serving mode for empty mode flag.
+// CGI is detected by environment and FastCGI by socket passed as standard input.
+func detectMode(mode string) (string, error) {
+	switch mode {
+	case modeHTTP, modeCGI, modeFCGI:
+		return mode, nil
+	case "":
+	default:
+		return "", fmt.Errorf("invalid mode %q: must be %s, %s or %s", mode, modeHTTP, modeCGI, modeFCGI)
+	}
+
+	if _, ok := os.LookupEnv(envGatewayInterface); ok {
+		return modeCGI, nil
+	}
+
+	if info, err := os.Stdin.Stat(); err == nil && info.Mode().Type() == os.ModeSocket {
+		return modeFCGI, nil
+	}
+
+	return modeHTTP, nil
+}
+
+// serveCGI serves a single request of the current CGI invocation.
+func serveCGI(handler http.Handler, logger *slog.Logger) error {
+	if err := cgi.Serve(handler); err != nil {
+		logger.Error("CGI request failure", "err", err)
+
+		return err
+	}
+
+	return nil
+}
+
+// serveFCGI serves FastCGI requests on configured listeners, or on a socket passed as standard input
+// when there are none. Listeners are closed when a signal is received.
+func serveFCGI(listeners []listener, handler, admin http.Handler, logger *slog.Logger, sigChan <-chan os.Signal) error {
+	if len(listeners) == 0 {
+		logger.Info("Serving FastCGI on standard input")
+
+		return fcgi.Serve(nil, handler)
+	}
+
+	errch := make(chan error, len(listeners))
+
+	var (
+		closed bool
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+	)
+
+	for _, l := range listeners {
+		h := handler
+		if l.admin {
+			h = admin
+		}
+
+		wg.Add(1)
+
+		go func(l net.Listener) {
+			defer wg.Done()
+
+			err := fcgi.Serve(l, h)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if !closed {
+				logger.Error("FastCGI server failure", "addr", l.Addr().String(), "err", err)
+				errch <- err
+			}
+		}(l)
+
+		logger.Info("Listening for FastCGI",
+			"addr", l.Addr().String(),
+			"network", l.Addr().Network(),
+			"admin", l.admin,
+		)
+	}
+
+	var err error
+
+	select {
+	case err = <-errch:
+	case <-sigChan:
+		logger.Info("Closing server")
+	}
+
+	mu.Lock()
+	closed = true
+	mu.Unlock()
+
+	for _, l := range listeners {
+		if closeErr := l.Close(); closeErr != nil && !errors.Is(closeErr, net.ErrClosed) {
+			logger.Error("Failed to close listener", "addr", l.Addr().String(), "err", closeErr)
+		}
+	}
+
+	wg.Wait()
+
+	return err
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const cgiTestConfig = `host: go.foo.dev
+packages:
+  - path: /bar
+    repository_url: https://github.com/foo/bar
+`
+
+func Test_detectMode(t *testing.T) {
+	tt := []struct {
+		name     string
+		mode     string
+		gateway  string
+		wantMode string
+		wantErr  bool
+	}{
+		{
+			name:     "default",
+			wantMode: modeHTTP,
+		},
+		{
+			name:     "explicit",
+			mode:     modeFCGI,
+			wantMode: modeFCGI,
+		},
+		{
+			name:     "cgi_env",
+			gateway:  "CGI/1.1",
+			wantMode: modeCGI,
+		},
+		{
+			name:     "explicit_over_env",
+			mode:     modeHTTP,
+			gateway:  "CGI/1.1",
+			wantMode: modeHTTP,
+		},
+		{
+			name:    "invalid",
+			mode:    "scgi",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.gateway != "" {
+				t.Setenv(envGatewayInterface, tc.gateway)
+			}
+
+			got, err := detectMode(tc.mode)
+			if tc.wantErr != (err != nil) {
+				t.Errorf("detectMode() = %v; wantErr = %v", err, tc.wantErr)
+			}
+
+			if got != tc.wantMode {
+				t.Errorf("detectMode() = %q; want %q", got, tc.wantMode)
+			}
+		})
+	}
+}
+
+func Test_runCGI(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfgName := filepath.Join(tmpDir, "vanityurl.yml")
+
+	if err := os.WriteFile(cfgName, []byte(cgiTestConfig), 0o600); err != nil {
+		t.Fatalf("failed to create temp config: %v", err)
+	}
+
+	for key, value := range map[string]string{
+		envGatewayInterface: "CGI/1.1",
+		"REQUEST_METHOD":    "GET",
+		"REQUEST_URI":       "/bar/baz?go-get=1",
+		"SERVER_PROTOCOL":   "HTTP/1.1",
+		"HTTP_HOST":         "go.foo.dev",
+		"REMOTE_ADDR":       "192.0.2.1",
+	} {
+		t.Setenv(key, value)
+	}
+
+	// CGI response is written to standard output
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create pipe: %v", err)
+	}
+
+	stdout := os.Stdout
+	os.Stdout = writer
+
+	err = run([]string{"-config", cfgName}, io.Discard, nil)
+
+	os.Stdout = stdout
+	_ = writer.Close()
+
+	if err != nil {
+		t.Fatalf("run() = %v", err)
+	}
+
+	output, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+
+	for _, want := range []string{
+		"Status: 200 OK",
+		"Content-Type: text/html; charset=utf-8",
+		`<meta name="go-import" content="go.foo.dev/bar git https://github.com/foo/bar">`,
+		"https://pkg.go.dev/go.foo.dev/bar/baz",
+	} {
+		if !strings.Contains(string(output), want) {
+			t.Errorf("CGI output does not contain %q:\n%s", want, output)
+		}
+	}
+}
+
+func Test_runFCGI(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	port, err := getFreePort()
+	if err != nil {
+		t.Fatalf("failed to get free port for listener")
+	}
+
+	cfgName := filepath.Join(tmpDir, "vanityurl.yml")
+	cfgContents := cgiTestConfig + fmt.Sprintf("listeners:\n  - addr: localhost:%d\n", port)
+
+	if err := os.WriteFile(cfgName, []byte(cfgContents), 0o600); err != nil {
+		t.Fatalf("failed to create temp config: %v", err)
+	}
+
+	sigch := make(chan os.Signal)
+	errch := make(chan error, 1)
+
+	go func() {
+		errch <- run([]string{"-config", cfgName, "-mode", modeFCGI}, io.Discard, sigch)
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+
+	output, err := fcgiGet(fmt.Sprintf("localhost:%d", port), map[string]string{
+		"REQUEST_METHOD":  "GET",
+		"REQUEST_URI":     "/bar?go-get=1",
+		"SERVER_PROTOCOL": "HTTP/1.1",
+		"HTTP_HOST":       "go.foo.dev",
+	})
+	if err != nil {
+		t.Fatalf("FastCGI request failed: %v", err)
+	}
+
+	for _, want := range []string{
+		"Status: 200 OK",
+		`<meta name="go-import" content="go.foo.dev/bar git https://github.com/foo/bar">`,
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("FastCGI output does not contain %q:\n%s", want, output)
+		}
+	}
+
+	sigch <- os.Kill
+
+	if err := <-errch; err != nil {
+		t.Fatalf("run() = got error = %v", err)
+	}
+}
+
+// fcgiGet sends a single FastCGI responder request and returns its standard output.
+func fcgiGet(addr string, params map[string]string) (string, error) {
+	const (
+		typeBeginRequest = 1
+		typeEndRequest   = 3
+		typeParams       = 4
+		typeStdin        = 5
+		typeStdout       = 6
+		roleResponder    = 1
+		requestID        = 1
+	)
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return "", err
+	}
+
+	defer conn.Close()
+
+	writeRecord := func(recType uint8, content []byte) error {
+		header := []byte{1, recType, 0, requestID, 0, 0, 0, 0}
+		binary.BigEndian.PutUint16(header[4:], uint16(len(content))) //nolint:gosec
+
+		_, err := conn.Write(append(header, content...))
+
+		return err
+	}
+
+	var pairs bytes.Buffer
+
+	for name, value := range params {
+		pairs.WriteByte(byte(len(name)))
+		pairs.WriteByte(byte(len(value)))
+		pairs.WriteString(name)
+		pairs.WriteString(value)
+	}
+
+	for _, rec := range []struct {
+		recType uint8
+		content []byte
+	}{
+		{typeBeginRequest, []byte{0, roleResponder, 0, 0, 0, 0, 0, 0}},
+		{typeParams, pairs.Bytes()},
+		{typeParams, nil},
+		{typeStdin, nil},
+	} {
+		if err := writeRecord(rec.recType, rec.content); err != nil {
+			return "", err
+		}
+	}
+
+	var stdout bytes.Buffer
+
+	reader := bufio.NewReader(conn)
+
+	for {
+		header := make([]byte, 8)
+		if _, err := io.ReadFull(reader, header); err != nil {
+			return "", err
+		}
+
+		content := make([]byte, int(binary.BigEndian.Uint16(header[4:]))+int(header[6]))
+		if _, err := io.ReadFull(reader, content); err != nil {
+			return "", err
+		}
+
+		switch header[1] {
+		case typeStdout:
+			stdout.Write(content[:binary.BigEndian.Uint16(header[4:])])
+		case typeEndRequest:
+			return stdout.String(), nil
+		}
+	}
+}
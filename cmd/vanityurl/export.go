@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+
+	"go.wamod.dev/vanityurl"
+)
+
+const (
+	flagOutVar        = "out"
+	defaultExportDir  = "public"
+	exportIndexFile   = "index.html"
+	exportNotFound    = "404.html"
+	exportNotFoundTpl = "notfound"
+)
+
+//nolint:gochecknoglobals
+var (
+	//go:embed export.gohtml
+	exportTmplRaw string
+
+	exportTmpl = template.Must(template.New("export").Parse(exportTmplRaw))
+)
+
+// export writes static site with one index.html per configured package, root index and 404 page.
+// The result can be hosted without the server, e.g. on GitHub Pages or S3.
+func export(args []string, stderr io.Writer) error {
+	logger := slog.New(slog.NewTextHandler(stderr, nil))
+
+	cfgName := stringValue{
+		value: defaultConfigFile,
+	}
+
+	fset := newFlagSet(cmdExport, &cfgName)
+	out := fset.String(flagOutVar, defaultExportDir, "Output directory")
+
+	if err := fset.Parse(args); err != nil {
+		return err
+	} else if !cfgName.set {
+		parseEnv(&cfgName)
+	}
+
+	cfg, err := loadConfig(cfgName.value)
+	if err != nil {
+		logger.Error("Failed to parse config", "err", err)
+
+		return err
+	}
+
+	if err := exportSite(cfg, *out, logger); err != nil {
+		logger.Error("Failed to export", "err", err)
+
+		return err
+	}
+
+	return nil
+}
+
+func exportSite(cfg yamlConfig, out string, logger *slog.Logger) error {
+	if cfg.Host == defaultHost {
+		return errors.New("host is required for export")
+	}
+
+	packages, err := cfg.packages()
+	if err != nil {
+		return err
+	}
+
+	warnScheduled(packages, logger)
+
+	resolver, err := vanityurl.NewResolver(packages...)
+	if err != nil {
+		return err
+	}
+
+	pset, err := resolver.(vanityurl.Lister).ListPackages(context.Background())
+	if err != nil {
+		return err
+	}
+
+	templates, err := loadTemplates(cfg.TemplatesDir)
+	if err != nil {
+		return err
+	}
+
+	// Static hosting can not answer 410 Gone or authenticate, so retired and private packages are left out
+	pset = slices.DeleteFunc(pset, func(pkg vanityurl.Package) bool {
+		if pkg.Retired {
+			logger.Info("Skipped retired package", "path", pkg.Path)
+		} else if pkg.Visibility == vanityurl.Private {
+			logger.Info("Skipped private package", "path", pkg.Path)
+		}
+
+		return pkg.Retired || pkg.Visibility == vanityurl.Private
+	})
+
+	hasRoot := false
+
+	for _, pkg := range pset {
+		if path.Clean(pkg.Path) != pkg.Path {
+			return fmt.Errorf("%w: path is not clean: %s", vanityurl.ErrInvalidPackage, pkg.Path)
+		}
+
+		hasRoot = hasRoot || pkg.Path == "/"
+
+		err := writeExportFile(out, pkg.Path, exportIndexFile, func(wr io.Writer) error {
+			return templates.RenderDocument(wr, pkg, cfg.Host, "")
+		})
+		if err != nil {
+			return err
+		}
+
+		logger.Info("Exported package", "path", pkg.Path)
+	}
+
+	if !hasRoot {
+		err := writeExportFile(out, "/", exportIndexFile, func(wr io.Writer) error {
+			return templates.RenderIndex(wr, cfg.Host, listedPackages(pset))
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	err = writeExportFile(out, "/", exportNotFound, func(wr io.Writer) error {
+		return renderExportNotFound(wr, templates, cfg.Host, pset)
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Exported site", "out", out, "packages_total", len(pset))
+
+	return nil
+}
+
+// renderExportNotFound renders 404 page for subpackage paths.
+// The go tool reads go-import meta tags even from 404 responses, so the page contains meta tags
+// for all packages not nested in another package, as nested ones would match ambiguously.
+// Browsers find the closest package with a script and are redirected same as by the server.
+func renderExportNotFound(wr io.Writer, templates *vanityurl.Templates, host string, pset []vanityurl.Package) error {
+	var heads bytes.Buffer
+
+	for _, pkg := range pset {
+		if isNestedPackage(pkg, pset) {
+			continue
+		}
+
+		if err := templates.RenderHead(&heads, pkg, host); err != nil {
+			return err
+		}
+
+		heads.WriteString("\n")
+	}
+
+	paths := make([]string, len(pset))
+	for i, pkg := range pset {
+		paths[i] = pkg.Path
+	}
+
+	// Longest paths first, so the closest package wins
+	slices.SortFunc(paths, func(a, b string) int {
+		return cmp.Or(len(b)-len(a), strings.Compare(a, b))
+	})
+
+	hostJSON, err := json.Marshal(host)
+	if err != nil {
+		return err
+	}
+
+	pathsJSON, err := json.Marshal(paths)
+	if err != nil {
+		return err
+	}
+
+	return exportTmpl.ExecuteTemplate(wr, exportNotFoundTpl, struct {
+		Heads        string
+		HostJSON     string
+		PackagesJSON string
+	}{
+		Heads:        strings.TrimSuffix(heads.String(), "\n"),
+		HostJSON:     string(hostJSON),
+		PackagesJSON: string(pathsJSON),
+	})
+}
+
+// listedPackages returns packages which may appear in the index.
+func listedPackages(pset []vanityurl.Package) []vanityurl.Package {
+	return slices.DeleteFunc(slices.Clone(pset), func(pkg vanityurl.Package) bool {
+		return !pkg.Visibility.Listed()
+	})
+}
+
+func isNestedPackage(pkg vanityurl.Package, pset []vanityurl.Package) bool {
+	for _, other := range pset {
+		if other.Path != pkg.Path && strings.HasPrefix(pkg.Path, strings.TrimSuffix(other.Path, "/")+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func writeExportFile(out, dir, name string, render func(io.Writer) error) error {
+	dir = filepath.Join(out, filepath.FromSlash(dir))
+
+	if err := os.MkdirAll(dir, 0o755); err != nil { //nolint:gosec
+		return err
+	}
+
+	var buf bytes.Buffer
+
+	if err := render(&buf); err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(dir, name), buf.Bytes(), 0o644) //nolint:gosec
+}
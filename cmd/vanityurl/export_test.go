@@ -0,0 +1,130 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.wamod.dev/vanityurl"
+)
+
+func Test_export(t *testing.T) {
+	tmpDir := t.TempDir()
+	out := filepath.Join(tmpDir, "public")
+
+	cfgName := filepath.Join(tmpDir, "vanityurl.yml")
+	cfgContents := strings.Join([]string{
+		`host: go.foo.dev`,
+		`packages:`,
+		`  - path: /bar`,
+		`    repository_url: https://github.com/foo/bar`,
+		`  - path: /bar/baz`,
+		`    repository_url: https://github.com/foo/baz`,
+		`  - path: /qux`,
+		`    repository_url: https://github.com/foo/qux`,
+		`  - path: /beta`,
+		`    repository_url: https://github.com/foo/beta`,
+		`    visibility: unlisted`,
+		`  - path: /internal`,
+		`    repository_url: https://github.com/foo/internal`,
+		`    visibility: private`,
+	}, "\n")
+
+	if err := os.WriteFile(cfgName, []byte(cfgContents), 0o600); err != nil {
+		t.Fatalf("failed to create temp config: %v", err)
+	}
+
+	if err := command([]string{cmdExport, "-config", cfgName, "-out", out}, io.Discard, io.Discard, nil); err != nil {
+		t.Fatalf("export() = %v", err)
+	}
+
+	cfg, err := loadConfig(cfgName)
+	if err != nil {
+		t.Fatalf("loadConfig() = %v", err)
+	}
+
+	packages, err := cfg.packages()
+	if err != nil {
+		t.Fatalf("yamlConfig.packages() = %v", err)
+	}
+
+	resolver, err := vanityurl.NewResolver(packages...)
+	if err != nil {
+		t.Fatalf("got error while creating resolver: %v", err)
+	}
+
+	srv := vanityurl.NewServer(resolver, &vanityurl.ServerOptions{Host: cfg.Host})
+
+	for _, pkgPath := range []string{"/bar", "/bar/baz", "/qux", "/beta"} {
+		t.Run(pkgPath, func(t *testing.T) {
+			got, err := os.ReadFile(filepath.Join(out, filepath.FromSlash(pkgPath), "index.html"))
+			if err != nil {
+				t.Fatalf("failed to read exported file: %v", err)
+			}
+
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, pkgPath+"?go-get=1", nil))
+
+			if want := rec.Body.String(); string(got) != want {
+				t.Errorf("exported %s = %s; want = %s", pkgPath, got, want)
+			}
+		})
+	}
+
+	index, err := os.ReadFile(filepath.Join(out, "index.html"))
+	if err != nil {
+		t.Fatalf("failed to read exported index: %v", err)
+	}
+
+	if want := `<a href="https://pkg.go.dev/go.foo.dev/bar/baz">`; !strings.Contains(string(index), want) {
+		t.Errorf("exported index = %s; want element = %s", index, want)
+	}
+
+	if unlisted := `go.foo.dev/beta`; strings.Contains(string(index), unlisted) {
+		t.Errorf("exported index = %s; unexpected unlisted package", index)
+	}
+
+	if _, err := os.Stat(filepath.Join(out, "internal")); !os.IsNotExist(err) {
+		t.Errorf("private package exported: %v", err)
+	}
+
+	notFound, err := os.ReadFile(filepath.Join(out, "404.html"))
+	if err != nil {
+		t.Fatalf("failed to read exported 404 page: %v", err)
+	}
+
+	wantInNotFound := []string{
+		`<meta name="go-import" content="go.foo.dev/bar git https://github.com/foo/bar">`,
+		`<meta name="go-import" content="go.foo.dev/qux git https://github.com/foo/qux">`,
+		`var host = "go.foo.dev";`,
+		`var packages = ["/bar/baz","/beta","/bar","/qux"];`,
+	}
+
+	for _, want := range wantInNotFound {
+		if !strings.Contains(string(notFound), want) {
+			t.Errorf("exported 404 page = %s; want element = %s", notFound, want)
+		}
+	}
+
+	if nested := `content="go.foo.dev/bar/baz git`; strings.Contains(string(notFound), nested) {
+		t.Errorf("exported 404 page = %s; unexpected nested package meta", notFound)
+	}
+}
+
+func Test_exportMissingHost(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfgName := filepath.Join(tmpDir, "vanityurl.yml")
+
+	if err := os.WriteFile(cfgName, []byte(`packages: []`), 0o600); err != nil {
+		t.Fatalf("failed to create temp config: %v", err)
+	}
+
+	err := export([]string{"-config", cfgName, "-out", filepath.Join(tmpDir, "out")}, io.Discard)
+	if err == nil {
+		t.Errorf("export() = nil; want error when host is missing")
+	}
+}
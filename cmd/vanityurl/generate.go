@@ -0,0 +1,477 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"go.wamod.dev/vanityurl"
+)
+
+const (
+	generateNginx  = "nginx"
+	generateCaddy  = "caddy"
+	generateApache = "apache"
+
+	// subpathMarker is replaced by the proxy specific subpath variable in rendered documents.
+	subpathMarker = "\x00subpath\x00"
+
+	notFoundBody = "Package not found\n"
+	goneBody     = "Package gone"
+)
+
+//nolint:gochecknoglobals
+var (
+	//go:embed generate.gotmpl
+	generateTmplRaw string
+
+	generateTmpl = template.Must(template.New("generate").Funcs(template.FuncMap{
+		"nginxQuote":   nginxQuote,
+		"nginxString":  nginxString,
+		"apacheQuote":  apacheQuote,
+		"apacheString": apacheString,
+		"apacheHeader": apacheHeader,
+		"apacheTarget": apacheTarget,
+		"nginxTarget":  nginxTarget,
+		"reverse":      reverseRoutes,
+	}).Parse(generateTmplRaw))
+
+	generators = map[string]func(io.Writer, generateData) error{
+		generateNginx:  generateTemplate(generateNginx),
+		generateCaddy:  generateCaddyJSON,
+		generateApache: generateTemplate(generateApache),
+	}
+)
+
+// generateRoute for a single package prefix and its subpaths.
+type generateRoute struct {
+	Path string
+	// Body of the document with [subpathMarker] in place of subpath, or retired reason if gone.
+	Body string
+	// Gone is set for retired packages answered with 410.
+	Gone bool
+	// ContentType and Headers of the document, including Cache-Control and package headers.
+	ContentType string
+	Headers     []generateHeader
+	// MovedTo path browsers are redirected to with 301, followed by subpath. The go tool still
+	// gets the document.
+	MovedTo string
+}
+
+type generateHeader struct {
+	Name  string
+	Value string
+}
+
+// Regexp matching package path with subpath as the first capture group.
+// Group name is used for a named capture, e.g. "?<subpath>", or empty for unnamed.
+func (r generateRoute) Regexp(group string) string {
+	if r.Path == "/" {
+		// Root package does not match other paths in resolver
+		return "^/(" + group + ")$"
+	}
+
+	return "^" + regexp.QuoteMeta(r.Path) + "(?:/(" + group + ".*))?$"
+}
+
+// RedirectRegexp matching package path with slash and subpath as the first capture group,
+// so it can be appended to [generateRoute.MovedTo] like the server does.
+func (r generateRoute) RedirectRegexp() string {
+	if r.Path == "/" {
+		return "^/()$"
+	}
+
+	return "^" + regexp.QuoteMeta(r.Path) + "(?:/|(/.+))?$"
+}
+
+type generateData struct {
+	Host   string
+	Routes []generateRoute
+	// NotFoundCacheAge in seconds, not cached if zero.
+	NotFoundCacheAge int64
+}
+
+// generate writes reverse proxy config answering the same documents as the server.
+func generate(args []string, stdout, stderr io.Writer) error {
+	logger := slog.New(slog.NewTextHandler(stderr, nil))
+
+	cfgName := stringValue{
+		value: defaultConfigFile,
+	}
+
+	fset := newFlagSet(cmdGenerate, &cfgName)
+	out := fset.String(flagOutVar, "", "Output file, defaults to stdout")
+
+	if err := fset.Parse(args); err != nil {
+		return err
+	} else if !cfgName.set {
+		parseEnv(&cfgName)
+	}
+
+	gen, ok := generators[fset.Arg(0)]
+	if fset.NArg() != 1 || !ok {
+		err := fmt.Errorf("expected one of %s, %s or %s", generateNginx, generateCaddy, generateApache)
+		logger.Error("Invalid generate target", "err", err)
+
+		return err
+	}
+
+	cfg, err := loadConfig(cfgName.value)
+	if err != nil {
+		logger.Error("Failed to parse config", "err", err)
+
+		return err
+	}
+
+	data, err := newGenerateData(cfg, logger)
+	if err != nil {
+		logger.Error("Failed to prepare packages", "err", err)
+
+		return err
+	}
+
+	var buf bytes.Buffer
+
+	if err := gen(&buf, data); err != nil {
+		logger.Error("Failed to generate config", "err", err)
+
+		return err
+	}
+
+	if *out != "" {
+		return os.WriteFile(*out, buf.Bytes(), 0o644) //nolint:gosec
+	}
+
+	_, err = stdout.Write(buf.Bytes())
+
+	return err
+}
+
+func newGenerateData(cfg yamlConfig, logger *slog.Logger) (generateData, error) {
+	if cfg.Host == defaultHost {
+		return generateData{}, errors.New("host is required for generate")
+	}
+
+	packages, err := cfg.packages()
+	if err != nil {
+		return generateData{}, err
+	}
+
+	warnScheduled(packages, logger)
+
+	resolver, err := vanityurl.NewResolver(packages...)
+	if err != nil {
+		return generateData{}, err
+	}
+
+	pset, err := resolver.(vanityurl.Lister).ListPackages(context.Background())
+	if err != nil {
+		return generateData{}, err
+	}
+
+	templates, err := loadTemplates(cfg.TemplatesDir)
+	if err != nil {
+		return generateData{}, err
+	}
+
+	// Longest paths first, so nested packages are matched before their parents like in resolver
+	slices.SortFunc(pset, func(a, b vanityurl.Package) int {
+		return cmp.Or(len(b.Path)-len(a.Path), strings.Compare(a.Path, b.Path))
+	})
+
+	// Generated configs can not authenticate clients, so private packages are left out
+	pset = slices.DeleteFunc(pset, func(pkg vanityurl.Package) bool {
+		return pkg.Visibility == vanityurl.Private
+	})
+
+	routes := make([]generateRoute, len(pset))
+
+	for i, pkg := range pset {
+		if pkg.Retired {
+			routes[i] = generateRoute{
+				Path: pkg.Path,
+				Body: cmp.Or(pkg.RetiredReason, goneBody) + "\n",
+				Gone: true,
+			}
+
+			continue
+		}
+
+		var body bytes.Buffer
+
+		if err := templates.RenderDocument(&body, pkg, cfg.Host, subpathMarker); err != nil {
+			return generateData{}, err
+		}
+
+		routes[i] = newDocumentRoute(pkg, body.String(), cmp.Or(pkg.CacheAge, cfg.CacheAge))
+	}
+
+	return generateData{
+		Host:   cfg.Host,
+		Routes: routes,
+
+		NotFoundCacheAge: int64(cfg.NotFoundCacheAge / time.Second),
+	}, nil
+}
+
+// newDocumentRoute with headers set the same way as by the server, package headers replacing defaults.
+func newDocumentRoute(pkg vanityurl.Package, body string, cacheAge time.Duration) generateRoute {
+	headers := map[string]string{
+		"Content-Type":  "text/html; charset=utf-8",
+		"Cache-Control": fmt.Sprintf("public, max-age=%d", cacheAge/time.Second),
+	}
+
+	for name, value := range pkg.Headers {
+		headers[http.CanonicalHeaderKey(name)] = value
+	}
+
+	route := generateRoute{
+		Path:        pkg.Path,
+		Body:        body,
+		ContentType: headers["Content-Type"],
+		MovedTo:     pkg.MovedTo,
+	}
+
+	delete(headers, "Content-Type")
+
+	for name, value := range headers {
+		route.Headers = append(route.Headers, generateHeader{Name: name, Value: value})
+	}
+
+	slices.SortFunc(route.Headers, func(a, b generateHeader) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return route
+}
+
+func generateTemplate(name string) func(io.Writer, generateData) error {
+	return func(wr io.Writer, data generateData) error {
+		return generateTmpl.ExecuteTemplate(wr, name, data)
+	}
+}
+
+// nginxQuote quotes string for nginx config.
+func nginxQuote(str string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`, "\r", `\r`).Replace(str) + `"`
+}
+
+// nginxString quotes text returned by nginx with subpath marker replaced by a named capture.
+// Nginx has no escape for '$', so such documents are rejected.
+func nginxString(str string) (string, error) {
+	if strings.Contains(str, "$") {
+		return "", fmt.Errorf("nginx can not return text containing '$': %q", str)
+	}
+
+	return strings.ReplaceAll(nginxQuote(str), subpathMarker, "${vanityurl_subpath}"), nil
+}
+
+// nginxTarget quotes rewrite replacement of redirect to path followed by the first capture,
+// dropping query string like the server.
+func nginxTarget(path string) (string, error) {
+	if strings.Contains(path, "$") {
+		return "", fmt.Errorf("nginx can not redirect to path containing '$': %q", path)
+	}
+
+	return nginxQuote(path + "$1?"), nil
+}
+
+// apacheQuote quotes directive argument for Apache config.
+func apacheQuote(str string) string {
+	return `"` + strings.ReplaceAll(str, `"`, `\"`) + `"`
+}
+
+// apacheHeader quotes header value for Apache, where '%' starts a format specifier.
+func apacheHeader(str string) string {
+	return apacheQuote(strings.ReplaceAll(str, "%", "%%"))
+}
+
+// apacheTarget quotes RewriteRule substitution of redirect to path followed by the first
+// capture of the last RewriteCond.
+func apacheTarget(path string) string {
+	return apacheQuote(strings.NewReplacer(`\`, `\\`, `$`, `\$`, `%`, `\%`).Replace(path) + "%1")
+}
+
+// apacheString quotes text as Apache string expression with subpath marker replaced by a named capture.
+func apacheString(str string) string {
+	str = strings.NewReplacer(
+		`\`, `\\`, `"`, `\"`, `%`, `\%`, `$`, `\$`, "\n", `\n`, "\t", `\t`, "\r", `\r`,
+	).Replace(str)
+
+	return `"` + strings.ReplaceAll(str, subpathMarker, "%{env:MATCH_VANITYURL_SUBPATH}") + `"`
+}
+
+// reverseRoutes for Apache, where the last matching section wins.
+func reverseRoutes(routes []generateRoute) []generateRoute {
+	routes = slices.Clone(routes)
+	slices.Reverse(routes)
+
+	return routes
+}
+
+// caddyConfig is a subset of Caddy JSON config.
+type caddyConfig struct {
+	Apps struct {
+		HTTP struct {
+			Servers map[string]caddyServer `json:"servers"`
+		} `json:"http"`
+	} `json:"apps"`
+}
+
+type caddyServer struct {
+	Listen []string     `json:"listen"`
+	Routes []caddyRoute `json:"routes"`
+}
+
+type caddyRoute struct {
+	Match    []caddyMatch  `json:"match"`
+	Handle   []caddyHandle `json:"handle"`
+	Terminal bool          `json:"terminal"`
+}
+
+type caddyMatch struct {
+	Host       []string            `json:"host,omitempty"`
+	PathRegexp *caddyRegexp        `json:"path_regexp,omitempty"`
+	Not        []caddyMatch        `json:"not,omitempty"`
+	Query      map[string][]string `json:"query,omitempty"`
+}
+
+type caddyRegexp struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+}
+
+type caddyHandle struct {
+	Handler    string              `json:"handler"`
+	StatusCode int                 `json:"status_code"`
+	Headers    map[string][]string `json:"headers"`
+	Body       string              `json:"body,omitempty"`
+}
+
+// generateCaddyJSON writes Caddy JSON config. Caddyfile is not used, because it expands
+// shorthand placeholders like {file} and {dir} which are present in go-source meta tags.
+func generateCaddyJSON(wr io.Writer, data generateData) error {
+	// Escape braces, so package fields are never treated as placeholders
+	escape := strings.NewReplacer(`{`, `\{`)
+
+	routes := make([]caddyRoute, 0, len(data.Routes)+1)
+
+	for i, route := range data.Routes {
+		name := fmt.Sprintf("vanityurl%d", i)
+		body := strings.ReplaceAll(escape.Replace(route.Body), subpathMarker, "{http.regexp."+name+".1}")
+
+		if route.Gone {
+			routes = append(routes, caddyRoute{
+				Match: []caddyMatch{{
+					Host:       []string{data.Host},
+					PathRegexp: &caddyRegexp{Name: name, Pattern: route.Regexp("")},
+				}},
+				Handle: []caddyHandle{{
+					Handler:    "static_response",
+					StatusCode: http.StatusGone,
+					Headers: map[string][]string{
+						"Content-Type":           {"text/plain; charset=utf-8"},
+						"X-Content-Type-Options": {"nosniff"},
+					},
+					Body: body,
+				}},
+				Terminal: true,
+			})
+
+			continue
+		}
+
+		headers := map[string][]string{
+			"Content-Type": {route.ContentType},
+		}
+
+		for _, header := range route.Headers {
+			headers[header.Name] = []string{escape.Replace(header.Value)}
+		}
+
+		if route.MovedTo != "" {
+			redirectName := name + "moved"
+			redirectHeaders := maps.Clone(headers)
+			redirectHeaders["Location"] = []string{escape.Replace(route.MovedTo) + "{http.regexp." + redirectName + ".1}"}
+
+			routes = append(routes, caddyRoute{
+				Match: []caddyMatch{{
+					Host:       []string{data.Host},
+					PathRegexp: &caddyRegexp{Name: redirectName, Pattern: route.RedirectRegexp()},
+					Not:        []caddyMatch{{Query: map[string][]string{"go-get": {"1"}}}},
+				}},
+				Handle: []caddyHandle{{
+					Handler:    "static_response",
+					StatusCode: http.StatusMovedPermanently,
+					Headers:    redirectHeaders,
+				}},
+				Terminal: true,
+			})
+		}
+
+		routes = append(routes, caddyRoute{
+			Match: []caddyMatch{{
+				Host:       []string{data.Host},
+				PathRegexp: &caddyRegexp{Name: name, Pattern: route.Regexp("")},
+			}},
+			Handle: []caddyHandle{{
+				Handler:    "static_response",
+				StatusCode: http.StatusOK,
+				Headers:    headers,
+				Body:       body,
+			}},
+			Terminal: true,
+		})
+	}
+
+	notFoundHeaders := map[string][]string{
+		"Content-Type":           {"text/plain; charset=utf-8"},
+		"X-Content-Type-Options": {"nosniff"},
+	}
+
+	if data.NotFoundCacheAge > 0 {
+		notFoundHeaders["Cache-Control"] = []string{fmt.Sprintf("public, max-age=%d", data.NotFoundCacheAge)}
+	}
+
+	routes = append(routes, caddyRoute{
+		Match: []caddyMatch{{
+			Host: []string{data.Host},
+		}},
+		Handle: []caddyHandle{{
+			Handler:    "static_response",
+			StatusCode: http.StatusNotFound,
+			Headers:    notFoundHeaders,
+			Body:       notFoundBody,
+		}},
+		Terminal: true,
+	})
+
+	var cfg caddyConfig
+
+	cfg.Apps.HTTP.Servers = map[string]caddyServer{
+		"vanityurl": {
+			Listen: []string{":443"},
+			Routes: routes,
+		},
+	}
+
+	enc := json.NewEncoder(wr)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(cfg)
+}
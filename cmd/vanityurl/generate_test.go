@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"go.wamod.dev/vanityurl"
+)
+
+//nolint:gochecknoglobals
+var updateGolden = flag.Bool("update", false, "update golden files")
+
+const generateTestConfig = `host: go.foo.dev
+cache_age: 1h
+not_found_cache_age: 1m
+packages:
+  - path: /bar
+    repository_url: https://github.com/foo/bar
+  - path: /bar/baz
+    repository_url: https://github.com/foo/baz
+  - path: /qux
+    vcs: git
+    repository_url: https://gitlab.com/foo/qux
+    display: https://gitlab.com/foo/qux https://gitlab.com/foo/qux/-/tree/main{/dir} https://gitlab.com/foo/qux/-/blob/main{/dir}/{file}#L{line}
+    cache_age: 5m
+    headers:
+      X-Robots-Tag: noindex
+  - path: /old
+    repository_url: https://github.com/foo/old
+    retired: true
+    retired_reason: Removed for legal reasons
+  - path: /oldlib
+    repository_url: https://github.com/foo/oldlib
+    moved_to: /newlib
+`
+
+func Test_generate(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfgName := filepath.Join(tmpDir, "vanityurl.yml")
+
+	if err := os.WriteFile(cfgName, []byte(generateTestConfig), 0o600); err != nil {
+		t.Fatalf("failed to create temp config: %v", err)
+	}
+
+	for _, target := range []string{generateNginx, generateCaddy, generateApache} {
+		t.Run(target, func(t *testing.T) {
+			var stdout bytes.Buffer
+
+			if err := command([]string{cmdGenerate, "-config", cfgName, target}, &stdout, io.Discard, nil); err != nil {
+				t.Fatalf("generate() = %v", err)
+			}
+
+			golden := filepath.Join("testdata", "generate_"+target+".golden")
+
+			if *updateGolden {
+				if err := os.WriteFile(golden, stdout.Bytes(), 0o644); err != nil { //nolint:gosec
+					t.Fatalf("failed to update golden file: %v", err)
+				}
+			}
+
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("failed to read golden file: %v", err)
+			}
+
+			if !bytes.Equal(stdout.Bytes(), want) {
+				t.Errorf("generate() mismatch with %s:\n%s", golden, stdout.String())
+			}
+		})
+	}
+
+	t.Run("out", func(t *testing.T) {
+		out := filepath.Join(tmpDir, "nginx.conf")
+
+		if err := command([]string{cmdGenerate, "-config", cfgName, "-out", out, generateNginx}, io.Discard, io.Discard, nil); err != nil {
+			t.Fatalf("generate() = %v", err)
+		}
+
+		if _, err := os.Stat(out); err != nil {
+			t.Errorf("generate() did not write output file: %v", err)
+		}
+	})
+
+	t.Run("invalid_target", func(t *testing.T) {
+		if err := command([]string{cmdGenerate, "-config", cfgName, "lighttpd"}, io.Discard, io.Discard, nil); err == nil {
+			t.Errorf("generate() = nil, want error")
+		}
+	})
+
+	t.Run("scheduled", func(t *testing.T) {
+		scheduled := filepath.Join(tmpDir, "scheduled.yml")
+		contents := generateTestConfig + strings.Join([]string{
+			`  - path: /later`,
+			`    repository_url: https://github.com/foo/later`,
+			`    publish_at: 2999-01-01T00:00:00Z`,
+			``,
+		}, "\n")
+
+		if err := os.WriteFile(scheduled, []byte(contents), 0o600); err != nil {
+			t.Fatalf("failed to create temp config: %v", err)
+		}
+
+		var stdout, stderr bytes.Buffer
+
+		if err := command([]string{cmdGenerate, "-config", scheduled, generateNginx}, &stdout, &stderr, nil); err != nil {
+			t.Fatalf("generate() = %v", err)
+		}
+
+		if strings.Contains(stdout.String(), "/later") {
+			t.Errorf("generate() included package not published yet:\n%s", stdout.String())
+		}
+
+		if !strings.Contains(stderr.String(), "level=WARN") || !strings.Contains(stderr.String(), "path=/later") {
+			t.Errorf("generate() logs = %s; want warning for scheduled package", stderr.String())
+		}
+	})
+
+	t.Run("missing_host", func(t *testing.T) {
+		noHost := filepath.Join(tmpDir, "nohost.yml")
+		contents := strings.TrimPrefix(generateTestConfig, "host: go.foo.dev\n")
+
+		if err := os.WriteFile(noHost, []byte(contents), 0o600); err != nil {
+			t.Fatalf("failed to create temp config: %v", err)
+		}
+
+		if err := command([]string{cmdGenerate, "-config", noHost, generateNginx}, io.Discard, io.Discard, nil); err == nil {
+			t.Errorf("generate() = nil, want error")
+		}
+	})
+}
+
+// Test_newGenerateData checks that routes match the same packages as the server and render the same bytes.
+func Test_newGenerateData(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfgName := filepath.Join(tmpDir, "vanityurl.yml")
+
+	if err := os.WriteFile(cfgName, []byte(generateTestConfig), 0o600); err != nil {
+		t.Fatalf("failed to create temp config: %v", err)
+	}
+
+	cfg, err := loadConfig(cfgName)
+	if err != nil {
+		t.Fatalf("loadConfig() = %v", err)
+	}
+
+	data, err := newGenerateData(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("newGenerateData() = %v", err)
+	}
+
+	packages, err := cfg.packages()
+	if err != nil {
+		t.Fatalf("yamlConfig.packages() = %v", err)
+	}
+
+	resolver, err := vanityurl.NewResolver(packages...)
+	if err != nil {
+		t.Fatalf("got error while creating resolver: %v", err)
+	}
+
+	srv := vanityurl.NewServer(resolver, &vanityurl.ServerOptions{Host: cfg.Host, CacheAge: cfg.CacheAge})
+
+	paths := []string{"/bar", "/bar/", "/bar/x/y", "/bar/baz", "/bar/baz/z", "/qux/a", "/old/x", "/oldlib", "/oldlib/", "/oldlib/x/y", "/barbaz", "/none"}
+
+	for _, reqPath := range paths {
+		for _, query := range []string{"?go-get=1", ""} {
+			t.Run(reqPath+query, func(t *testing.T) {
+				r := httptest.NewRequest(http.MethodGet, reqPath+query, nil)
+				w := httptest.NewRecorder()
+
+				srv.ServeHTTP(w, r)
+
+				var body, location string
+
+				status := http.StatusNotFound
+
+				// Routes are matched in order like nginx regex locations and Caddy routes
+				for _, route := range data.Routes {
+					match := regexp.MustCompile(route.Regexp("")).FindStringSubmatch(reqPath)
+					if match == nil {
+						continue
+					}
+
+					body, status = strings.ReplaceAll(route.Body, subpathMarker, match[1]), http.StatusOK
+
+					if route.Gone {
+						status = http.StatusGone
+					} else if route.MovedTo != "" && query == "" {
+						redirect := regexp.MustCompile(route.RedirectRegexp()).FindStringSubmatch(reqPath)
+						status, location = http.StatusMovedPermanently, route.MovedTo+redirect[1]
+					}
+
+					break
+				}
+
+				if status != w.Code {
+					t.Fatalf("route status = %d, server status = %d", status, w.Code)
+				}
+
+				switch status {
+				case http.StatusNotFound:
+				case http.StatusMovedPermanently:
+					if want := w.Header().Get("Location"); location != want {
+						t.Errorf("route location = %q, want %q", location, want)
+					}
+				default:
+					if body != w.Body.String() {
+						t.Errorf("route body = %q, want %q", body, w.Body.String())
+					}
+				}
+			})
+		}
+	}
+}
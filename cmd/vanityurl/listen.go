@@ -0,0 +1,225 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	listenSchemeTCP     = "tcp"
+	listenSchemeUnix    = "unix"
+	listenSchemeSystemd = "systemd"
+
+	envListenPID     = "LISTEN_PID"
+	envListenFDs     = "LISTEN_FDS"
+	envListenFDNames = "LISTEN_FDNAMES"
+
+	// listenFDsStart is the first file descriptor passed by systemd socket activation.
+	listenFDsStart = 3
+)
+
+type yamlListener struct {
+	// Addr is "tcp:host:port", "unix:/path/to.sock", "systemd:" or "systemd:name".
+	// Address without scheme is treated as tcp.
+	Addr string `yaml:"addr"`
+	// Mode of unix socket file, e.g. "0660".
+	Mode string `yaml:"mode"`
+	// Admin listener serves only health, readiness, version and metrics endpoints.
+	Admin bool `yaml:"admin"`
+}
+
+func (l yamlListener) validate() error {
+	scheme, addr := splitListenAddr(l.Addr)
+
+	switch scheme {
+	case listenSchemeTCP:
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return fmt.Errorf("invalid listener addr %q: %w", l.Addr, err)
+		}
+	case listenSchemeUnix:
+		if addr == "" {
+			return fmt.Errorf("invalid listener addr %q: missing socket path", l.Addr)
+		}
+	}
+
+	if l.Mode != "" {
+		if scheme != listenSchemeUnix {
+			return fmt.Errorf("invalid listener mode %q: only allowed for unix sockets", l.Mode)
+		}
+
+		if _, err := parseFileMode(l.Mode); err != nil {
+			return fmt.Errorf("invalid listener mode %q: %w", l.Mode, err)
+		}
+	}
+
+	return nil
+}
+
+// listener bound to configured address.
+type listener struct {
+	net.Listener
+	admin bool
+}
+
+// listen opens listeners for a given config. Systemd listeners may return more than one listener.
+func listen(cfg yamlListener) ([]listener, error) {
+	scheme, addr := splitListenAddr(cfg.Addr)
+
+	var (
+		listeners []net.Listener
+		err       error
+	)
+
+	switch scheme {
+	case listenSchemeUnix:
+		listeners, err = listenUnix(addr, cfg.Mode)
+	case listenSchemeSystemd:
+		listeners, err = listenSystemd(addr)
+	default:
+		listeners, err = listenTCP(addr)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]listener, len(listeners))
+	for i, l := range listeners {
+		result[i] = listener{Listener: l, admin: cfg.Admin}
+	}
+
+	return result, nil
+}
+
+func listenTCP(addr string) ([]net.Listener, error) {
+	l, err := net.Listen(listenSchemeTCP, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return []net.Listener{l}, nil
+}
+
+func listenUnix(path, mode string) ([]net.Listener, error) {
+	// Remove stale socket left after unclean shutdown
+	if info, err := os.Stat(path); err == nil && info.Mode().Type() == os.ModeSocket {
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket: %w", err)
+		}
+	}
+
+	l, err := net.Listen(listenSchemeUnix, path)
+	if err != nil {
+		return nil, err
+	}
+
+	if mode != "" {
+		perm, err := parseFileMode(mode)
+		if err != nil {
+			_ = l.Close()
+
+			return nil, err
+		}
+
+		if err := os.Chmod(path, perm); err != nil {
+			_ = l.Close()
+
+			return nil, fmt.Errorf("failed to change socket mode: %w", err)
+		}
+	}
+
+	return []net.Listener{l}, nil
+}
+
+// listenSystemd returns listeners inherited with systemd socket activation.
+// If name is not empty then only listeners with a given FileDescriptorName are returned.
+func listenSystemd(name string) ([]net.Listener, error) {
+	count, names, err := parseListenFDs(
+		os.Getenv(envListenPID),
+		os.Getenv(envListenFDs),
+		os.Getenv(envListenFDNames),
+		os.Getpid(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var listeners []net.Listener
+
+	for i := range count {
+		if name != "" && (i >= len(names) || names[i] != name) {
+			continue
+		}
+
+		file := os.NewFile(uintptr(listenFDsStart+i), "systemd:"+name)
+
+		l, err := net.FileListener(file)
+
+		_ = file.Close()
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to use inherited listener %d: %w", listenFDsStart+i, err)
+		}
+
+		listeners = append(listeners, l)
+	}
+
+	if len(listeners) == 0 {
+		return nil, fmt.Errorf("no inherited systemd listeners found for %q", name)
+	}
+
+	return listeners, nil
+}
+
+// parseListenFDs parses systemd socket activation environment variables.
+func parseListenFDs(pidStr, fdsStr, namesStr string, pid int) (int, []string, error) {
+	if pidStr == "" || fdsStr == "" {
+		return 0, nil, errors.New("systemd socket activation variables are not set")
+	}
+
+	listenPID, err := strconv.Atoi(pidStr)
+	if err != nil {
+		return 0, nil, fmt.Errorf("invalid %s: %w", envListenPID, err)
+	} else if listenPID != pid {
+		return 0, nil, fmt.Errorf("%s %d does not match process id %d", envListenPID, listenPID, pid)
+	}
+
+	count, err := strconv.Atoi(fdsStr)
+	if err != nil || count < 0 {
+		return 0, nil, fmt.Errorf("invalid %s: %s", envListenFDs, fdsStr)
+	}
+
+	var names []string
+	if namesStr != "" {
+		names = strings.Split(namesStr, ":")
+	}
+
+	return count, names, nil
+}
+
+func splitListenAddr(addr string) (string, string) {
+	scheme, rest, ok := strings.Cut(addr, ":")
+	if ok {
+		switch scheme {
+		case listenSchemeTCP, listenSchemeUnix, listenSchemeSystemd:
+			return scheme, rest
+		}
+	}
+
+	return listenSchemeTCP, addr
+}
+
+func parseFileMode(mode string) (os.FileMode, error) {
+	perm, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil {
+		return 0, err
+	} else if perm > 0o777 {
+		return 0, fmt.Errorf("mode %s out of range", mode)
+	}
+
+	return os.FileMode(perm), nil
+}
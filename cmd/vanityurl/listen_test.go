@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_yamlListener_validate(t *testing.T) {
+	tt := []struct {
+		name     string
+		listener yamlListener
+		wantErr  bool
+	}{
+		{name: "tcp_port", listener: yamlListener{Addr: ":8080"}},
+		{name: "tcp_scheme", listener: yamlListener{Addr: "tcp:127.0.0.1:9090", Admin: true}},
+		{name: "unix", listener: yamlListener{Addr: "unix:/run/vanityurl.sock", Mode: "0660"}},
+		{name: "systemd", listener: yamlListener{Addr: "systemd:"}},
+		{name: "systemd_name", listener: yamlListener{Addr: "systemd:admin"}},
+		{name: "tcp_missing_port", listener: yamlListener{Addr: "localhost"}, wantErr: true},
+		{name: "unix_missing_path", listener: yamlListener{Addr: "unix:"}, wantErr: true},
+		{name: "invalid_mode", listener: yamlListener{Addr: "unix:/run/vanityurl.sock", Mode: "rw"}, wantErr: true},
+		{name: "mode_out_of_range", listener: yamlListener{Addr: "unix:/run/vanityurl.sock", Mode: "7777"}, wantErr: true},
+		{name: "mode_for_tcp", listener: yamlListener{Addr: ":8080", Mode: "0660"}, wantErr: true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.listener.validate()
+			if tc.wantErr != (err != nil) {
+				t.Errorf("yamlListener.validate() = %v; wantErr = %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func Test_listenUnix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vanityurl.sock")
+
+	for _, mode := range []string{"0600", "0660"} {
+		listeners, err := listen(yamlListener{Addr: "unix:" + path, Mode: mode})
+		if err != nil {
+			t.Fatalf("listen() = %v", err)
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("failed to stat socket: %v", err)
+		}
+
+		if want, _ := parseFileMode(mode); info.Mode().Perm() != want {
+			t.Errorf("socket mode = %v; want = %v", info.Mode().Perm(), want)
+		}
+
+		// Leave stale socket file behind to check it is replaced
+		if l, ok := listeners[0].Listener.(interface{ SetUnlinkOnClose(bool) }); ok {
+			l.SetUnlinkOnClose(false)
+		}
+
+		_ = listeners[0].Close()
+	}
+}
+
+func Test_parseListenFDs(t *testing.T) {
+	tt := []struct {
+		name      string
+		pid       string
+		fds       string
+		names     string
+		wantCount int
+		wantNames []string
+		wantErr   bool
+	}{
+		{name: "not_set", wantErr: true},
+		{name: "other_pid", pid: "2", fds: "1", wantErr: true},
+		{name: "invalid_fds", pid: "1", fds: "x", wantErr: true},
+		{name: "single", pid: "1", fds: "1", wantCount: 1},
+		{name: "named", pid: "1", fds: "2", names: "http:admin", wantCount: 2, wantNames: []string{"http", "admin"}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			count, names, err := parseListenFDs(tc.pid, tc.fds, tc.names, 1)
+			if tc.wantErr != (err != nil) {
+				t.Errorf("parseListenFDs() = %v; wantErr = %v", err, tc.wantErr)
+			}
+
+			if count != tc.wantCount || !reflect.DeepEqual(names, tc.wantNames) {
+				t.Errorf("parseListenFDs() = %d, %v; want = %d, %v", count, names, tc.wantCount, tc.wantNames)
+			}
+		})
+	}
+}
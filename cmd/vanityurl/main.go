@@ -1,6 +1,9 @@
 package main
 
 import (
+	"cmp"
+	"context"
+	"crypto/tls"
 	"errors"
 	"flag"
 	"fmt"
@@ -8,11 +11,16 @@ import (
 	"log"
 	"log/slog"
 	"net/http"
+	"net/netip"
 	"os"
 	"os/signal"
+	"slices"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
+	"golang.org/x/crypto/acme/autocert"
 	"gopkg.in/yaml.v3"
 
 	"go.wamod.dev/vanityurl"
@@ -20,12 +28,23 @@ import (
 )
 
 const (
+	cmdExport   = "export"
+	cmdGenerate = "generate"
+
 	flagConfigVar     = "config"
 	envConfigVar      = "VANITYURL_CONFIG"
 	defaultConfigFile = "vanityurl.yml"
 	defaultHost       = "0.0.0.0"
 	defaultPort       = 8080
 	defaultCacheAge   = 24 * time.Hour
+
+	defaultShutdownTimeout = 10 * time.Second
+	defaultTimeout         = 5 * time.Second
+	defaultMaxPathLength   = 1024
+	defaultMaxSubpathDepth = 32
+
+	accessLogFormatText = "text"
+	accessLogFormatJSON = "json"
 )
 
 func main() {
@@ -33,46 +52,132 @@ func main() {
 
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
-	if err := run(os.Args, os.Stderr, sigChan); err != nil {
+	if err := command(os.Args[1:], os.Stdout, os.Stderr, sigChan); err != nil {
 		os.Exit(1)
 	}
 }
 
+// command runs subcommand named by the first argument, or the server if no subcommand is given.
+func command(args []string, stdout, stderr io.Writer, sigChan <-chan os.Signal) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case cmdExport:
+			return export(args[1:], stderr)
+		case cmdGenerate:
+			return generate(args[1:], stdout, stderr)
+		}
+	}
+
+	return run(args, stderr, sigChan)
+}
+
 func run(args []string, stderr io.Writer, sigChan <-chan os.Signal) error {
 	logger := slog.New(slog.NewTextHandler(stderr, nil))
 	logger.Info("Starting server", "version", version.Version())
 
-	cfg, err := parseConfig(args)
+	var modeFlag stringValue
+
+	cfg, err := parseConfig(args, &modeFlag)
 	if err != nil {
 		logger.Error("Failed to parse config", "err", err)
 
 		return err
 	}
 
-	logger.Info("Loaded config", slog.Group("config",
+	mode, err := detectMode(modeFlag.value)
+	if err == nil && mode != modeHTTP && cfg.TLS.Enabled() {
+		err = fmt.Errorf("tls is not supported in %s mode, it is terminated by web server", mode)
+	}
+
+	if err != nil {
+		logger.Error("Failed to configure mode", "err", err)
+
+		return err
+	}
+
+	logger.Info("Loaded config", "mode", mode, slog.Group("config",
 		"host", cfg.Host,
 		"port", cfg.Port,
 		"cache_age", cfg.CacheAge,
+		"not_found_cache_age", cfg.NotFoundCacheAge,
 		"packages_total", len(cfg.Packages),
+		"templates_dir", cfg.TemplatesDir,
+		"listeners_total", len(cfg.Listeners),
+		slog.Group("endpoints",
+			"health", cfg.Endpoints.Health,
+			"ready", cfg.Endpoints.Ready,
+			"version", cfg.Endpoints.Version,
+			"metrics", cfg.Endpoints.Metrics,
+		),
+		slog.Group("access_log",
+			"enabled", cfg.AccessLog.Enabled,
+			"format", cfg.AccessLog.Format,
+			"file", cfg.AccessLog.File,
+		),
+		"trusted_proxies_total", len(cfg.TrustedProxies),
+		"ip_rules_total", len(cfg.IPRules),
+		slog.Group("rate_limit",
+			"go_get_rate", cfg.RateLimit.GoGet.Rate,
+			"go_get_burst", cfg.RateLimit.GoGet.Burst,
+			"browser_rate", cfg.RateLimit.Browser.Rate,
+			"browser_burst", cfg.RateLimit.Browser.Burst,
+			"idle_timeout", cfg.RateLimit.IdleTimeout,
+		),
+		slog.Group("auth",
+			"enabled", cfg.Auth.Enabled(),
+			"htpasswd_file", cfg.Auth.HtpasswdFile,
+			"users_total", len(cfg.Auth.Users),
+			"tokens_total", len(cfg.Auth.Tokens),
+			"stealth", cfg.Auth.Stealth,
+		),
+		"read_timeout", cfg.ReadTimeout,
+		"read_header_timeout", cfg.ReadHeaderTimeout,
+		"write_timeout", cfg.WriteTimeout,
+		"idle_timeout", cfg.IdleTimeout,
+		"max_header_bytes", cfg.MaxHeaderBytes,
+		"max_path_length", cfg.MaxPathLength,
+		"max_subpath_depth", cfg.MaxSubpathDepth,
+		slog.Group("tls",
+			"cert_file", cfg.TLS.CertFile,
+			"key_file", cfg.TLS.KeyFile,
+			"min_version", cfg.TLS.MinVersion,
+			"redirect_port", cfg.TLS.RedirectPort,
+			slog.Group("acme",
+				"enabled", cfg.TLS.ACME.Enabled,
+				"hosts", cfg.TLS.ACME.Hosts,
+				"email", cfg.TLS.ACME.Email,
+				"cache_dir", cfg.TLS.ACME.CacheDir,
+				"directory_url", cfg.TLS.ACME.DirectoryURL,
+				"challenge", cfg.TLS.ACME.Challenge,
+			),
+		),
+		"shutdown_timeout", cfg.ShutdownTimeout,
+		"shutdown_delay", cfg.ShutdownDelay,
 	))
 
-	packages := make([]vanityurl.Package, len(cfg.Packages))
-
 	for i, pkg := range cfg.Packages {
 		logger.Info("Configuring package", slog.Group("package",
 			"id", i,
 			"path", pkg.Path,
 			"display", pkg.Display,
-			"vcs", pkg.VCS.String(),
+			"vcs", pkg.VCS,
 			"repository_url", pkg.RepositoryURL,
+			"moved_to", pkg.MovedTo,
+			"retired", pkg.Retired,
+			"aliases", pkg.Aliases,
+			"canonical", pkg.Canonical,
+			"cache_age", pkg.CacheAge,
+			"visibility", pkg.Visibility,
+			"publish_at", pkg.PublishAt,
+			"expire_at", pkg.ExpireAt,
 		))
+	}
 
-		packages[i] = vanityurl.Package{
-			Path:          pkg.Path,
-			Display:       pkg.Display,
-			VCS:           pkg.VCS.Value,
-			RepositoryURL: pkg.RepositoryURL,
-		}
+	packages, err := cfg.packages()
+	if err != nil {
+		logger.Error("Failed to configure packages", "err", err)
+
+		return err
 	}
 
 	logger.Info("Creating resolver")
@@ -84,69 +189,464 @@ func run(args []string, stderr io.Writer, sigChan <-chan os.Signal) error {
 		return err
 	}
 
-	srv := http.Server{
-		Addr: fmt.Sprintf(":%d", cfg.Port),
-		Handler: vanityurl.NewServer(resolver, &vanityurl.ServerOptions{
-			Host:     cfg.Host,
-			CacheAge: cfg.CacheAge,
-		}),
-		ErrorLog:          log.Default(),
-		ReadTimeout:       5 * time.Second,
-		ReadHeaderTimeout: 5 * time.Second,
-		WriteTimeout:      5 * time.Second,
-		IdleTimeout:       5 * time.Second,
+	templates, err := loadTemplates(cfg.TemplatesDir)
+	if err != nil {
+		logger.Error("Failed to load templates", "err", err)
+
+		return err
 	}
 
-	errch := make(chan error)
-	donech := make(chan struct{})
+	var (
+		authorizer vanityurl.Authorizer
+		identify   func(*http.Request) string
+	)
 
-	go func() {
-		<-sigChan
+	if cfg.Auth.Enabled() {
+		auth, err := newAuth(cfg.Auth)
+		if err != nil {
+			logger.Error("Failed to configure auth", "err", err)
 
-		logger.Info("Closing server")
+			return err
+		}
 
-		if err := srv.Close(); err != nil {
-			logger.Error("Failed to close server", "err", err)
-			errch <- err
+		authorizer, identify = auth, auth.Identify
+	}
+
+	handler := vanityurl.NewServer(resolver, &vanityurl.ServerOptions{
+		Host:        cfg.Host,
+		CacheAge:    cfg.CacheAge,
+		HealthPath:  cfg.Endpoints.Health,
+		ReadyPath:   cfg.Endpoints.Ready,
+		VersionPath: cfg.Endpoints.Version,
+		MetricsPath: cfg.Endpoints.Metrics,
+		Version:     version.Version(),
+		Templates:   templates,
+		Authorizer:  authorizer,
+		Logger:      logger,
+
+		IPRules:        ipRules(cfg.IPRules),
+		TrustedProxies: prefixes(cfg.TrustedProxies),
+
+		MaxPathLength:    cfg.MaxPathLength,
+		MaxSubpathDepth:  cfg.MaxSubpathDepth,
+		NotFoundCacheAge: cfg.NotFoundCacheAge,
+	})
+
+	if err := checkReservedPaths(packages, handler.ReservedPaths()); err != nil {
+		logger.Error("Failed to configure endpoints", "err", err)
+
+		return err
+	}
+
+	// Admin listeners serve endpoints, so public listeners serve only packages
+	var public http.Handler = handler
+	if slices.ContainsFunc(cfg.Listeners, func(l yamlListener) bool { return l.Admin }) {
+		public = handler.PackagesHandler()
+	}
+
+	if cfg.RateLimit.Enabled() {
+		public = vanityurl.NewRateLimitHandler(public, &vanityurl.RateLimitOptions{
+			GoGet:          vanityurl.RateLimit(cfg.RateLimit.GoGet),
+			Browser:        vanityurl.RateLimit(cfg.RateLimit.Browser),
+			TrustedProxies: prefixes(cfg.TrustedProxies),
+			Identify:       identify,
+			ExemptPaths:    handler.ReservedPaths(),
+			IdleTimeout:    cfg.RateLimit.IdleTimeout,
+			MaxClients:     cfg.RateLimit.MaxClients,
+		})
+	}
+
+	publicHandler, closeAccessLog, err := newAccessLogHandler(public, cfg.AccessLog, stderr, cfg.TrustedProxies)
+	if err != nil {
+		logger.Error("Failed to configure access log", "err", err)
+
+		return err
+	}
+
+	defer closeAccessLog()
+
+	switch mode {
+	case modeCGI:
+		return serveCGI(publicHandler, logger)
+	case modeFCGI:
+		var listeners []listener
+
+		// Without configured listeners FastCGI is served on a socket passed by web server
+		if len(cfg.Listeners) > 0 {
+			listeners, err = openListeners(cfg)
+			if err != nil {
+				logger.Error("Failed to listen", "err", err)
+
+				return err
+			}
 		}
-	}()
 
-	go func() {
-		err := srv.ListenAndServe()
-		if errors.Is(err, http.ErrServerClosed) {
-			donech <- struct{}{}
+		return serveFCGI(listeners, publicHandler, handler.EndpointsHandler(), logger, sigChan)
+	}
+
+	var (
+		tlsConfig *tls.Config
+		manager   *autocert.Manager
+	)
+
+	if cfg.TLS.ACME.Enabled {
+		manager = newACMEManager(cfg.TLS.ACME)
+		tlsConfig = newACMETLSConfig(manager, tlsVersions[cfg.TLS.MinVersion])
+
+		logger.Info("Using ACME certificates", "hosts", cfg.TLS.ACME.Hosts, "cache_dir", cfg.TLS.ACME.CacheDir)
+	} else if cfg.TLS.Enabled() {
+		tlsConfig, err = newTLSConfig(cfg.TLS, logger)
+		if err != nil {
+			logger.Error("Failed to configure TLS", "err", err)
+
+			return err
+		}
+	}
+
+	listeners, err := openListeners(cfg)
+	if err != nil {
+		logger.Error("Failed to listen", "err", err)
+
+		return err
+	}
+
+	var redirect http.Handler
+
+	if cfg.TLS.RedirectPort != 0 {
+		// Redirects target port of TLS listeners, which may differ from configured port
+		httpsPort, err := redirectPort(listeners)
+		if err != nil {
+			for _, l := range listeners {
+				_ = l.Close()
+			}
+
+			logger.Error("Failed to configure HTTPS redirects", "err", err)
+
+			return err
+		}
+
+		redirect = redirectHandler(httpsPort)
+		if manager != nil {
+			redirect = manager.HTTPHandler(redirect)
+		}
+	}
+
+	servers := make([]*http.Server, 0, len(listeners)+1)
+	errch := make(chan error, len(listeners)+1)
+
+	for _, l := range listeners {
+		srv := newHTTPServer(cfg, l.Addr().String(), publicHandler)
+
+		if l.admin {
+			srv.Handler = handler.EndpointsHandler()
+		}
+
+		servers = append(servers, srv)
+		useTLS := tlsConfig != nil && !l.admin
+
+		if useTLS {
+			srv.TLSConfig = tlsConfig
+
+			go serve(srv, func() error { return srv.ServeTLS(l, "", "") }, logger, errch)
 		} else {
-			logger.Error("HTTP server failure", "err", err)
-			errch <- err
+			go serve(srv, func() error { return srv.Serve(l) }, logger, errch)
 		}
-	}()
 
-	logger.Info("Listening", "addr", srv.Addr)
+		logger.Info("Listening",
+			"addr", l.Addr().String(),
+			"network", l.Addr().Network(),
+			"tls", useTLS,
+			"admin", l.admin,
+		)
+	}
+
+	if redirect != nil {
+		redirectSrv := newHTTPServer(cfg, fmt.Sprintf(":%d", cfg.TLS.RedirectPort), redirect)
+		servers = append(servers, redirectSrv)
+
+		go serve(redirectSrv, redirectSrv.ListenAndServe, logger, errch)
+
+		logger.Info("Listening for HTTPS redirects", "addr", redirectSrv.Addr)
+	}
 
 	select {
 	case err := <-errch:
+		for _, srv := range servers {
+			_ = srv.Close()
+		}
+
 		return err
-	case <-donech:
+	case <-sigChan:
+	}
+
+	return shutdown(servers, handler, cfg, logger, sigChan)
+}
+
+// openListeners from config, or a single TCP listener on configured port.
+func openListeners(cfg yamlConfig) ([]listener, error) {
+	configs := cfg.Listeners
+	if len(configs) == 0 {
+		configs = []yamlListener{{Addr: fmt.Sprintf(":%d", cfg.Port)}}
+	}
+
+	var listeners []listener
+
+	for _, lcfg := range configs {
+		ll, err := listen(lcfg)
+		if err != nil {
+			for _, l := range listeners {
+				_ = l.Close()
+			}
+
+			return nil, fmt.Errorf("failed to listen on %s: %w", lcfg.Addr, err)
+		}
+
+		listeners = append(listeners, ll...)
+	}
+
+	return listeners, nil
+}
+
+func newHTTPServer(cfg yamlConfig, addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ErrorLog:          log.Default(),
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+	}
+}
+
+// serve runs HTTP server and reports failure to errch.
+func serve(srv *http.Server, serveFn func() error, logger *slog.Logger, errch chan<- error) {
+	err := serveFn()
+	if !errors.Is(err, http.ErrServerClosed) {
+		logger.Error("HTTP server failure", "addr", srv.Addr, "err", err)
+		errch <- err
+	}
+}
+
+// shutdown marks server as not ready, waits for shutdown delay and gracefully shuts down servers.
+// Servers are closed immediately if another signal is received or shutdown timeout exceeds.
+func shutdown(
+	servers []*http.Server,
+	handler *vanityurl.Server,
+	cfg yamlConfig,
+	logger *slog.Logger,
+	sigChan <-chan os.Signal,
+) error {
+	logger.Info("Closing server", "delay", cfg.ShutdownDelay, "timeout", cfg.ShutdownTimeout)
+
+	handler.SetReady(false)
+
+	select {
+	case <-time.After(cfg.ShutdownDelay):
+	case <-sigChan:
+		return forceClose(servers, logger)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	donech := make(chan error, 1)
+
+	go func() {
+		errs := make([]error, len(servers))
+
+		var wg sync.WaitGroup
+
+		for i, srv := range servers {
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+
+				errs[i] = srv.Shutdown(ctx)
+			}()
+		}
+
+		wg.Wait()
+
+		donech <- errors.Join(errs...)
+	}()
+
+	select {
+	case err := <-donech:
+		if err != nil {
+			logger.Error("Failed to shutdown server gracefully", "err", err)
+
+			for _, srv := range servers {
+				_ = srv.Close()
+			}
+
+			return err
+		}
+
 		return nil
+	case <-sigChan:
+		return forceClose(servers, logger)
+	}
+}
+
+func forceClose(servers []*http.Server, logger *slog.Logger) error {
+	logger.Warn("Forcing server close")
+
+	errs := make([]error, len(servers))
+
+	for i, srv := range servers {
+		errs[i] = srv.Close()
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		logger.Error("Failed to close server", "err", err)
+
+		return err
+	}
+
+	return nil
+}
+
+// checkReservedPaths fails if any of reserved endpoint paths is resolved as a package.
+// Publish and expiry times are ignored, so packages published later are checked as well.
+func checkReservedPaths(packages []vanityurl.Package, paths []string) error {
+	unscheduled := make([]vanityurl.Package, len(packages))
+	for i, pkg := range packages {
+		pkg.PublishAt, pkg.ExpireAt = time.Time{}, time.Time{}
+		unscheduled[i] = pkg
+	}
+
+	resolver, err := vanityurl.NewResolver(unscheduled...)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		pkg, err := resolver.ResolvePackage(context.Background(), path)
+		if errors.Is(err, vanityurl.ErrPackageNotFound) {
+			continue
+		} else if err != nil && !errors.Is(err, vanityurl.ErrPackageGone) {
+			return err
+		}
+
+		return fmt.Errorf("reserved path %s collides with package %s", path, pkg.Path)
 	}
+
+	return nil
+}
+
+// warnScheduled logs packages with publish or expiry time, as static output includes only
+// packages active when it is written and does not change at these times.
+func warnScheduled(packages []vanityurl.Package, logger *slog.Logger) {
+	now := time.Now()
+
+	for _, pkg := range packages {
+		if pkg.PublishAt.IsZero() && pkg.ExpireAt.IsZero() {
+			continue
+		}
+
+		logger.Warn("Scheduled package is written as of now, regenerate at its publish and expiry times",
+			"path", pkg.Path,
+			"publish_at", pkg.PublishAt,
+			"expire_at", pkg.ExpireAt,
+			"included", pkg.Active(now),
+		)
+	}
+}
+
+// newAccessLogHandler wraps handler with access log middleware if enabled.
+// Returned function closes access log file.
+func newAccessLogHandler(
+	handler http.Handler,
+	cfg yamlAccessLog,
+	stderr io.Writer,
+	trustedProxies []yamlPrefix,
+) (http.Handler, func(), error) {
+	if !cfg.Enabled {
+		return handler, func() {}, nil
+	}
+
+	out, closeFn := stderr, func() {}
+
+	if cfg.File != "" {
+		file, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644) //nolint:gosec
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open access log file: %w", err)
+		}
+
+		out, closeFn = file, func() { _ = file.Close() }
+	}
+
+	var logHandler slog.Handler
+
+	switch cfg.Format {
+	case accessLogFormatJSON:
+		logHandler = slog.NewJSONHandler(out, nil)
+	default:
+		logHandler = slog.NewTextHandler(out, nil)
+	}
+
+	return vanityurl.NewAccessLogHandler(handler, slog.New(logHandler), &vanityurl.AccessLogOptions{
+		TrustedProxies: prefixes(trustedProxies),
+	}), closeFn, nil
+}
+
+func prefixes(values []yamlPrefix) []netip.Prefix {
+	prefixes := make([]netip.Prefix, len(values))
+	for i, prefix := range values {
+		prefixes[i] = prefix.Value
+	}
+
+	return prefixes
 }
 
-func parseConfig(args []string) (yamlConfig, error) {
+func ipRules(values []yamlIPRule) []vanityurl.IPRule {
+	rules := make([]vanityurl.IPRule, len(values))
+	for i, rule := range values {
+		rules[i] = vanityurl.IPRule{
+			Prefix: rule.Prefix,
+			Allow:  prefixes(rule.Allow),
+			Deny:   prefixes(rule.Deny),
+		}
+	}
+
+	return rules
+}
+
+// loadTemplates from a given directory, or default templates if dir is empty.
+func loadTemplates(dir string) (*vanityurl.Templates, error) {
+	if dir == "" {
+		return vanityurl.DefaultTemplates(), nil
+	}
+
+	return vanityurl.ParseTemplatesFS(os.DirFS(dir))
+}
+
+func parseConfig(args []string, mode *stringValue) (yamlConfig, error) {
 	cfgName := stringValue{
 		value: defaultConfigFile,
 	}
 
-	if err := parseFlags(args, &cfgName); err != nil {
+	if err := parseFlags(args, &cfgName, mode); err != nil {
 		return yamlConfig{}, err
 	} else if !cfgName.set {
 		parseEnv(&cfgName)
 	}
 
-	file, err := os.Open(cfgName.value)
+	return loadConfig(cfgName.value)
+}
+
+// loadConfig from a given file and fill in defaults.
+func loadConfig(name string) (yamlConfig, error) {
+	file, err := os.Open(name)
 	if err != nil {
 		return yamlConfig{}, fmt.Errorf("failed to open config file: %w", err)
 	}
 
+	defer file.Close()
+
 	var cfg yamlConfig
 
 	err = yaml.NewDecoder(file).Decode(&cfg)
@@ -166,57 +666,289 @@ func parseConfig(args []string) (yamlConfig, error) {
 		cfg.CacheAge = defaultCacheAge
 	}
 
+	if cfg.NotFoundCacheAge < 0 {
+		return yamlConfig{}, errors.New("not found cache age must not be negative")
+	}
+
+	cfg.ReadTimeout = cmp.Or(cfg.ReadTimeout, defaultTimeout)
+	// Default header timeout stays within read timeout, so setting only read_timeout is valid
+	cfg.ReadHeaderTimeout = cmp.Or(cfg.ReadHeaderTimeout, max(min(defaultTimeout, cfg.ReadTimeout), 0))
+	cfg.WriteTimeout = cmp.Or(cfg.WriteTimeout, defaultTimeout)
+	cfg.IdleTimeout = cmp.Or(cfg.IdleTimeout, defaultTimeout)
+	cfg.MaxHeaderBytes = cmp.Or(cfg.MaxHeaderBytes, http.DefaultMaxHeaderBytes)
+	cfg.MaxPathLength = cmp.Or(cfg.MaxPathLength, defaultMaxPathLength)
+	cfg.MaxSubpathDepth = cmp.Or(cfg.MaxSubpathDepth, defaultMaxSubpathDepth)
+
+	if err := cfg.validateLimits(); err != nil {
+		return yamlConfig{}, err
+	}
+
+	if cfg.ShutdownTimeout == 0 {
+		cfg.ShutdownTimeout = defaultShutdownTimeout
+	}
+
+	if cfg.ShutdownTimeout < 0 || cfg.ShutdownDelay < 0 {
+		return yamlConfig{}, errors.New("shutdown timeout and delay must not be negative")
+	}
+
+	for _, l := range cfg.Listeners {
+		if err := l.validate(); err != nil {
+			return yamlConfig{}, err
+		}
+	}
+
+	if cfg.TLS.Enabled() {
+		cfg.TLS.MinVersion = cmp.Or(cfg.TLS.MinVersion, defaultTLSMinVersion)
+	}
+
+	if cfg.TLS.ACME.Enabled {
+		if len(cfg.TLS.ACME.Hosts) == 0 && cfg.Host != defaultHost {
+			cfg.TLS.ACME.Hosts = []string{cfg.Host}
+		}
+
+		cfg.TLS.ACME.CacheDir = cmp.Or(cfg.TLS.ACME.CacheDir, defaultACMECacheDir)
+		cfg.TLS.ACME.Challenge = cmp.Or(cfg.TLS.ACME.Challenge, acmeChallengeTLSALPN)
+	}
+
+	if err := cfg.TLS.validate(); err != nil {
+		return yamlConfig{}, err
+	}
+
+	if err := cfg.Endpoints.validate(); err != nil {
+		return yamlConfig{}, err
+	}
+
+	if err := cfg.AccessLog.validate(); err != nil {
+		return yamlConfig{}, err
+	}
+
+	for _, rule := range cfg.IPRules {
+		if err := rule.validate(); err != nil {
+			return yamlConfig{}, err
+		}
+	}
+
+	if err := cfg.RateLimit.validate(); err != nil {
+		return yamlConfig{}, err
+	}
+
+	if _, err := cfg.packages(); err != nil {
+		return yamlConfig{}, err
+	}
+
 	return cfg, nil
 }
 
+// packages converts configured packages to [vanityurl.Package].
+func (cfg yamlConfig) packages() ([]vanityurl.Package, error) {
+	packages := make([]vanityurl.Package, len(cfg.Packages))
+
+	for i, pkgCfg := range cfg.Packages {
+		pkg, err := pkgCfg.Package()
+		if err != nil {
+			return nil, fmt.Errorf("invalid package %s: %w", pkgCfg.Path, err)
+		}
+
+		packages[i] = pkg
+	}
+
+	return packages, nil
+}
+
 type yamlConfig struct {
-	Host     string        `yaml:"host"`
-	Port     uint          `yaml:"port"`
-	CacheAge time.Duration `yaml:"cache_age"`
-	Packages []yamlPackage `yaml:"packages"`
+	Host      string        `yaml:"host"`
+	Port      uint          `yaml:"port"`
+	CacheAge  time.Duration `yaml:"cache_age"`
+	Endpoints yamlEndpoints `yaml:"endpoints"`
+
+	// NotFoundCacheAge for 404 responses, not cached if zero.
+	NotFoundCacheAge time.Duration `yaml:"not_found_cache_age"`
+
+	ReadTimeout       time.Duration `yaml:"read_timeout"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	WriteTimeout      time.Duration `yaml:"write_timeout"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout"`
+	MaxHeaderBytes    int           `yaml:"max_header_bytes"`
+	MaxPathLength     int           `yaml:"max_path_length"`
+	MaxSubpathDepth   int           `yaml:"max_subpath_depth"`
+
+	Listeners []yamlListener `yaml:"listeners"`
+	TLS       yamlTLS        `yaml:"tls"`
+
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	ShutdownDelay   time.Duration `yaml:"shutdown_delay"`
+
+	AccessLog yamlAccessLog             `yaml:"access_log"`
+	Packages  []vanityurl.PackageConfig `yaml:"packages"`
+
+	// TemplatesDir with custom templates, e.g. "document.gohtml". Defaults are used for missing files.
+	TemplatesDir string `yaml:"templates_dir"`
+
+	TrustedProxies []yamlPrefix `yaml:"trusted_proxies"`
+	IPRules        []yamlIPRule `yaml:"ip_rules"`
+
+	RateLimit yamlRateLimit `yaml:"rate_limit"`
+
+	// Auth credentials for private packages.
+	Auth yamlAuth `yaml:"auth"`
+}
+
+func (cfg yamlConfig) validateLimits() error {
+	for name, timeout := range map[string]time.Duration{
+		"read_timeout":        cfg.ReadTimeout,
+		"read_header_timeout": cfg.ReadHeaderTimeout,
+		"write_timeout":       cfg.WriteTimeout,
+		"idle_timeout":        cfg.IdleTimeout,
+	} {
+		if timeout < 0 {
+			return fmt.Errorf("invalid %s %v: must not be negative", name, timeout)
+		}
+	}
+
+	if cfg.ReadHeaderTimeout > cfg.ReadTimeout {
+		return fmt.Errorf("invalid read_header_timeout %v: must not exceed read_timeout %v",
+			cfg.ReadHeaderTimeout, cfg.ReadTimeout)
+	}
+
+	for name, limit := range map[string]int{
+		"max_header_bytes":  cfg.MaxHeaderBytes,
+		"max_path_length":   cfg.MaxPathLength,
+		"max_subpath_depth": cfg.MaxSubpathDepth,
+	} {
+		if limit < 0 {
+			return fmt.Errorf("invalid %s %d: must not be negative", name, limit)
+		}
+	}
+
+	return nil
+}
+
+type yamlEndpoints struct {
+	Health  string `yaml:"health"`
+	Ready   string `yaml:"ready"`
+	Version string `yaml:"version"`
+	Metrics string `yaml:"metrics"`
+}
+
+func (e yamlEndpoints) validate() error {
+	seen := map[string]struct{}{}
+
+	for _, path := range []string{e.Health, e.Ready, e.Version, e.Metrics} {
+		if path == "" {
+			continue
+		}
+
+		if !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("invalid endpoint path %q: must start with /", path)
+		}
+
+		if _, ok := seen[path]; ok {
+			return fmt.Errorf("invalid endpoint path %q: used more than once", path)
+		}
+
+		seen[path] = struct{}{}
+	}
+
+	return nil
+}
+
+type yamlAccessLog struct {
+	Enabled bool   `yaml:"enabled"`
+	Format  string `yaml:"format"`
+	File    string `yaml:"file"`
 }
 
-type yamlPackage struct {
-	Path          string  `yaml:"path"`
-	VCS           yamlVCS `yaml:"vcs"`
-	Display       string  `yaml:"display"`
-	RepositoryURL string  `yaml:"repository_url"`
+func (l yamlAccessLog) validate() error {
+	switch l.Format {
+	case "", accessLogFormatText, accessLogFormatJSON:
+		return nil
+	default:
+		return fmt.Errorf("invalid access log format %q: must be %s or %s", l.Format, accessLogFormatText, accessLogFormatJSON)
+	}
 }
 
-type yamlVCS struct {
-	Value vanityurl.VCS
+type yamlRateLimit struct {
+	GoGet       yamlRate      `yaml:"go_get"`
+	Browser     yamlRate      `yaml:"browser"`
+	IdleTimeout time.Duration `yaml:"idle_timeout"`
+	MaxClients  int           `yaml:"max_clients"`
 }
 
-func (vcs yamlVCS) MarshalYAML() (interface{}, error) { //nolint:unparam
-	return vcs.String(), nil
+// yamlRate of requests per second and burst, converted to [vanityurl.RateLimit].
+type yamlRate struct {
+	Rate  float64 `yaml:"rate"`
+	Burst int     `yaml:"burst"`
 }
 
-func (vcs *yamlVCS) UnmarshalYAML(value *yaml.Node) error {
-	var strPtr *string
+// Enabled reports whether any of limits is set.
+func (l yamlRateLimit) Enabled() bool {
+	return l.GoGet.Rate > 0 || l.Browser.Rate > 0
+}
 
-	err := value.Decode(&strPtr)
+func (l yamlRateLimit) validate() error {
+	if l.GoGet.Rate < 0 || l.Browser.Rate < 0 || l.GoGet.Burst < 0 || l.Browser.Burst < 0 ||
+		l.IdleTimeout < 0 || l.MaxClients < 0 {
+		return errors.New("invalid rate limit: rate, burst, idle_timeout and max_clients must not be negative")
+	}
+
+	return nil
+}
+
+// yamlIPRule allows or denies clients for paths under prefix, or all paths if prefix is empty.
+type yamlIPRule struct {
+	Prefix string       `yaml:"prefix"`
+	Allow  []yamlPrefix `yaml:"allow"`
+	Deny   []yamlPrefix `yaml:"deny"`
+}
+
+func (rule yamlIPRule) validate() error {
+	if rule.Prefix != "" && !strings.HasPrefix(rule.Prefix, "/") {
+		return fmt.Errorf("invalid ip rule prefix %q: must start with /", rule.Prefix)
+	} else if len(rule.Allow) == 0 && len(rule.Deny) == 0 {
+		return fmt.Errorf("invalid ip rule for prefix %q: allow or deny is required", rule.Prefix)
+	}
+
+	return nil
+}
+
+// yamlPrefix is a CIDR prefix or a single IP address.
+type yamlPrefix struct {
+	Value netip.Prefix
+}
+
+func (p yamlPrefix) MarshalYAML() (interface{}, error) { //nolint:unparam
+	return p.Value.String(), nil
+}
+
+func (p *yamlPrefix) UnmarshalYAML(value *yaml.Node) error {
+	var str string
+
+	err := value.Decode(&str)
 	if err != nil {
 		return err
-	} else if strPtr == nil {
-		vcs.Value = 0
+	}
+
+	if !strings.Contains(str, "/") {
+		addr, err := netip.ParseAddr(str)
+		if err != nil {
+			return err
+		}
+
+		p.Value = netip.PrefixFrom(addr, addr.BitLen())
 
 		return nil
 	}
 
-	parsed, err := vanityurl.ParseVCS(*strPtr)
+	prefix, err := netip.ParsePrefix(str)
 	if err != nil {
 		return err
 	}
 
-	vcs.Value = parsed
+	p.Value = prefix.Masked()
 
 	return nil
 }
 
-func (vcs yamlVCS) String() string {
-	return vcs.Value.String()
-}
-
 type stringValue struct {
 	value string
 	set   bool
@@ -233,12 +965,19 @@ func (v *stringValue) String() string {
 	return v.value
 }
 
-func parseFlags(args []string, cfgName *stringValue) error {
-	fset := flag.NewFlagSet("", flag.ContinueOnError)
+func parseFlags(args []string, cfgName, mode *stringValue) error {
+	fset := newFlagSet("", cfgName)
+	fset.Var(mode, flagModeVar, "Serving mode: http, cgi or fcgi. Detected from environment if not set")
+
+	return fset.Parse(args)
+}
+
+func newFlagSet(name string, cfgName *stringValue) *flag.FlagSet {
+	fset := flag.NewFlagSet(name, flag.ContinueOnError)
 
 	fset.Var(cfgName, flagConfigVar, "Config file location")
 
-	return fset.Parse(args)
+	return fset
 }
 
 func parseEnv(cfgName *stringValue) {
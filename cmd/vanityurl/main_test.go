@@ -2,9 +2,13 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"io"
+	"log/slog"
 	"net"
 	"net/http"
+	"net/netip"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -110,6 +114,216 @@ func Test_run(t *testing.T) {
 	}
 }
 
+func Test_runListeners(t *testing.T) {
+	tmpDir := t.TempDir()
+	socket := filepath.Join(tmpDir, "vanityurl.sock")
+
+	port, err := getFreePort()
+	if err != nil {
+		t.Fatalf("failed to get free port for listener")
+	}
+
+	cfgName := filepath.Join(tmpDir, "vanityurl.yml")
+	cfgContents := strings.Join([]string{
+		`host: go.foo.dev`,
+		`endpoints:`,
+		`  health: /healthz`,
+		`listeners:`,
+		`  - addr: unix:` + socket,
+		`    mode: "0660"`,
+		fmt.Sprintf("  - addr: tcp:localhost:%d", port),
+		`    admin: true`,
+		`packages:`,
+		`  - path: /bar`,
+		`    repository_url: https://github.com/foo/bar`,
+	}, "\n")
+
+	err = os.WriteFile(cfgName, []byte(cfgContents), 0o600)
+	if err != nil {
+		t.Fatalf("failed to create temp config: %v", err)
+	}
+
+	sigch := make(chan os.Signal)
+	errch := make(chan error, 1)
+
+	go func() {
+		errch <- run([]string{"-config", cfgName}, io.Discard, sigch)
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+
+	unixClient := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+
+	tt := []struct {
+		name       string
+		client     *http.Client
+		url        string
+		wantStatus int
+	}{
+		{
+			name:       "public_package",
+			client:     unixClient,
+			url:        "http://go.foo.dev/bar",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "public_endpoint",
+			client:     unixClient,
+			url:        "http://go.foo.dev/healthz",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "admin_endpoint",
+			client:     http.DefaultClient,
+			url:        fmt.Sprintf("http://localhost:%d/healthz", port),
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "admin_package",
+			client:     http.DefaultClient,
+			url:        fmt.Sprintf("http://localhost:%d/bar", port),
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := tc.client.Get(tc.url) //nolint:noctx
+			if err != nil {
+				t.Fatalf("got error from HTTP handler: %v", err)
+			}
+
+			defer res.Body.Close()
+
+			if res.StatusCode != tc.wantStatus {
+				t.Errorf("response.StatusCode = %d; want %d", res.StatusCode, tc.wantStatus)
+			}
+		})
+	}
+
+	sigch <- os.Kill
+
+	if err := <-errch; err != nil {
+		t.Fatalf("run() = got error = %v", err)
+	}
+
+	if _, err := os.Stat(socket); !os.IsNotExist(err) {
+		t.Errorf("socket file was not removed on shutdown: %v", err)
+	}
+}
+
+func Test_shutdown(t *testing.T) {
+	tt := []struct {
+		name         string
+		secondSignal bool
+		wantStatus   int
+		wantErr      bool
+	}{
+		{
+			name:       "drain",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:         "force_close",
+			secondSignal: true,
+			wantErr:      true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			resolver, err := vanityurl.NewResolver()
+			if err != nil {
+				t.Fatalf("got error while creating resolver: %v", err)
+			}
+
+			handler := vanityurl.NewServer(resolver, nil)
+			started := make(chan struct{})
+			release := make(chan struct{})
+
+			srv := &http.Server{
+				Handler: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+					close(started)
+					<-release
+					w.WriteHeader(http.StatusOK)
+				}),
+				ReadHeaderTimeout: time.Second,
+			}
+
+			listener, err := net.Listen("tcp", "localhost:0")
+			if err != nil {
+				t.Fatalf("failed to listen: %v", err)
+			}
+
+			go func() {
+				_ = srv.Serve(listener)
+			}()
+
+			reqErr := make(chan error, 1)
+			reqStatus := make(chan int, 1)
+
+			go func() {
+				res, err := http.Get("http://" + listener.Addr().String()) //nolint:noctx
+				if err != nil {
+					reqErr <- err
+
+					return
+				}
+
+				defer res.Body.Close()
+
+				reqStatus <- res.StatusCode
+			}()
+
+			<-started
+
+			sigch := make(chan os.Signal, 1)
+			donech := make(chan error, 1)
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+			go func() {
+				donech <- shutdown([]*http.Server{srv}, handler, yamlConfig{ShutdownTimeout: 5 * time.Second}, logger, sigch)
+			}()
+
+			if tc.secondSignal {
+				sigch <- os.Interrupt
+			} else {
+				time.Sleep(50 * time.Millisecond)
+				close(release)
+			}
+
+			if err := <-donech; err != nil {
+				t.Errorf("shutdown() = %v", err)
+			}
+
+			if handler.Ready(context.Background()) == nil {
+				t.Errorf("Server.Ready() = nil; want error after shutdown")
+			}
+
+			select {
+			case status := <-reqStatus:
+				if tc.wantErr || status != tc.wantStatus {
+					t.Errorf("response.StatusCode = %d; want %d", status, tc.wantStatus)
+				}
+			case err := <-reqErr:
+				if !tc.wantErr {
+					t.Errorf("got error from HTTP handler: %v", err)
+				}
+			}
+
+			if tc.secondSignal {
+				close(release)
+			}
+		})
+	}
+}
+
 func Test_parseConfig(t *testing.T) {
 	tmpDir := t.TempDir()
 
@@ -129,9 +343,17 @@ func Test_parseConfig(t *testing.T) {
 			},
 			wantErr: false,
 			wantConfig: yamlConfig{
-				Host:     defaultHost,
-				Port:     defaultPort,
-				CacheAge: defaultCacheAge,
+				Host:              defaultHost,
+				Port:              defaultPort,
+				CacheAge:          defaultCacheAge,
+				ReadTimeout:       defaultTimeout,
+				ReadHeaderTimeout: defaultTimeout,
+				WriteTimeout:      defaultTimeout,
+				IdleTimeout:       defaultTimeout,
+				MaxHeaderBytes:    http.DefaultMaxHeaderBytes,
+				MaxPathLength:     defaultMaxPathLength,
+				MaxSubpathDepth:   defaultMaxSubpathDepth,
+				ShutdownTimeout:   defaultShutdownTimeout,
 			},
 		},
 		{
@@ -148,9 +370,17 @@ func Test_parseConfig(t *testing.T) {
 				}, "\n"),
 			},
 			wantConfig: yamlConfig{
-				Host:     "go.env.dev",
-				Port:     1234,
-				CacheAge: 123 * time.Second,
+				Host:              "go.env.dev",
+				Port:              1234,
+				CacheAge:          123 * time.Second,
+				ReadTimeout:       defaultTimeout,
+				ReadHeaderTimeout: defaultTimeout,
+				WriteTimeout:      defaultTimeout,
+				IdleTimeout:       defaultTimeout,
+				MaxHeaderBytes:    http.DefaultMaxHeaderBytes,
+				MaxPathLength:     defaultMaxPathLength,
+				MaxSubpathDepth:   defaultMaxSubpathDepth,
+				ShutdownTimeout:   defaultShutdownTimeout,
 			},
 		},
 		{
@@ -169,20 +399,423 @@ func Test_parseConfig(t *testing.T) {
 				}, "\n"),
 			},
 			wantConfig: yamlConfig{
-				Host:     "go.full.dev",
-				Port:     1234,
-				CacheAge: 123 * time.Second,
-				Packages: []yamlPackage{
+				Host:              "go.full.dev",
+				Port:              1234,
+				CacheAge:          123 * time.Second,
+				ReadTimeout:       defaultTimeout,
+				ReadHeaderTimeout: defaultTimeout,
+				WriteTimeout:      defaultTimeout,
+				IdleTimeout:       defaultTimeout,
+				MaxHeaderBytes:    http.DefaultMaxHeaderBytes,
+				MaxPathLength:     defaultMaxPathLength,
+				MaxSubpathDepth:   defaultMaxSubpathDepth,
+				ShutdownTimeout:   defaultShutdownTimeout,
+				Packages: []vanityurl.PackageConfig{
 					{
 						Path:          "/foo",
 						RepositoryURL: "https://git.example.dev/example-dev/foo",
 						Display:       "foo_display",
-						VCS: yamlVCS{
-							Value: vanityurl.Git,
-						},
+						VCS:           "git",
+					},
+				},
+			},
+		},
+		{
+			name: "endpoints",
+			args: []string{"-config", filepath.Join(tmpDir, "endpoints.yml")},
+			files: map[string]string{
+				"endpoints.yml": strings.Join([]string{
+					`endpoints:`,
+					`  health: /-/healthz`,
+					`  ready: /-/readyz`,
+					`  version: /-/version`,
+					`  metrics: /-/metrics`,
+				}, "\n"),
+			},
+			wantConfig: yamlConfig{
+				Host:              defaultHost,
+				Port:              defaultPort,
+				CacheAge:          defaultCacheAge,
+				ReadTimeout:       defaultTimeout,
+				ReadHeaderTimeout: defaultTimeout,
+				WriteTimeout:      defaultTimeout,
+				IdleTimeout:       defaultTimeout,
+				MaxHeaderBytes:    http.DefaultMaxHeaderBytes,
+				MaxPathLength:     defaultMaxPathLength,
+				MaxSubpathDepth:   defaultMaxSubpathDepth,
+				ShutdownTimeout:   defaultShutdownTimeout,
+				Endpoints: yamlEndpoints{
+					Health:  "/-/healthz",
+					Ready:   "/-/readyz",
+					Version: "/-/version",
+					Metrics: "/-/metrics",
+				},
+			},
+		},
+		{
+			name: "invalid_endpoint",
+			args: []string{"-config", filepath.Join(tmpDir, "invalid_endpoint.yml")},
+			files: map[string]string{
+				"invalid_endpoint.yml": strings.Join([]string{
+					`endpoints:`,
+					`  health: healthz`,
+				}, "\n"),
+			},
+			wantErr: true,
+		},
+		{
+			name: "duplicate_endpoint",
+			args: []string{"-config", filepath.Join(tmpDir, "duplicate_endpoint.yml")},
+			files: map[string]string{
+				"duplicate_endpoint.yml": strings.Join([]string{
+					`endpoints:`,
+					`  health: /healthz`,
+					`  ready: /healthz`,
+				}, "\n"),
+			},
+			wantErr: true,
+		},
+		{
+			name: "access_log",
+			args: []string{"-config", filepath.Join(tmpDir, "access_log.yml")},
+			files: map[string]string{
+				"access_log.yml": strings.Join([]string{
+					`access_log:`,
+					`  enabled: true`,
+					`  format: json`,
+					`  file: /var/log/vanityurl.log`,
+					`trusted_proxies:`,
+					`  - 10.0.0.0/8`,
+					`  - 192.168.1.1`,
+				}, "\n"),
+			},
+			wantConfig: yamlConfig{
+				Host:              defaultHost,
+				Port:              defaultPort,
+				CacheAge:          defaultCacheAge,
+				ReadTimeout:       defaultTimeout,
+				ReadHeaderTimeout: defaultTimeout,
+				WriteTimeout:      defaultTimeout,
+				IdleTimeout:       defaultTimeout,
+				MaxHeaderBytes:    http.DefaultMaxHeaderBytes,
+				MaxPathLength:     defaultMaxPathLength,
+				MaxSubpathDepth:   defaultMaxSubpathDepth,
+				ShutdownTimeout:   defaultShutdownTimeout,
+				AccessLog: yamlAccessLog{
+					Enabled: true,
+					Format:  "json",
+					File:    "/var/log/vanityurl.log",
+				},
+				TrustedProxies: []yamlPrefix{
+					{Value: netip.MustParsePrefix("10.0.0.0/8")},
+					{Value: netip.MustParsePrefix("192.168.1.1/32")},
+				},
+			},
+		},
+		{
+			name: "invalid_access_log_format",
+			args: []string{"-config", filepath.Join(tmpDir, "invalid_access_log_format.yml")},
+			files: map[string]string{
+				"invalid_access_log_format.yml": strings.Join([]string{
+					`access_log:`,
+					`  format: xml`,
+				}, "\n"),
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid_trusted_proxy",
+			args: []string{"-config", filepath.Join(tmpDir, "invalid_trusted_proxy.yml")},
+			files: map[string]string{
+				"invalid_trusted_proxy.yml": strings.Join([]string{
+					`trusted_proxies:`,
+					`  - 10.0.0.0/99`,
+				}, "\n"),
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid_visibility",
+			args: []string{"-config", filepath.Join(tmpDir, "invalid_visibility.yml")},
+			files: map[string]string{
+				"invalid_visibility.yml": strings.Join([]string{
+					`packages:`,
+					`  - path: /foo`,
+					`    repository_url: https://github.com/example/foo`,
+					`    visibility: hidden`,
+				}, "\n"),
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid_ip_rule",
+			args: []string{"-config", filepath.Join(tmpDir, "invalid_ip_rule.yml")},
+			files: map[string]string{
+				"invalid_ip_rule.yml": strings.Join([]string{
+					`ip_rules:`,
+					`  - prefix: internal`,
+					`    allow: [10.0.0.0/8]`,
+				}, "\n"),
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid_rate_limit",
+			args: []string{"-config", filepath.Join(tmpDir, "invalid_rate_limit.yml")},
+			files: map[string]string{
+				"invalid_rate_limit.yml": strings.Join([]string{
+					`rate_limit:`,
+					`  go_get:`,
+					`    rate: -1`,
+				}, "\n"),
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative_rate_limit_max_clients",
+			args: []string{"-config", filepath.Join(tmpDir, "negative_rate_limit_max_clients.yml")},
+			files: map[string]string{
+				"negative_rate_limit_max_clients.yml": strings.Join([]string{
+					`rate_limit:`,
+					`  go_get:`,
+					`    rate: 1`,
+					`  max_clients: -1`,
+				}, "\n"),
+			},
+			wantErr: true,
+		},
+		{
+			name: "shutdown",
+			args: []string{"-config", filepath.Join(tmpDir, "shutdown.yml")},
+			files: map[string]string{
+				"shutdown.yml": strings.Join([]string{
+					`shutdown_timeout: 30s`,
+					`shutdown_delay: 5s`,
+				}, "\n"),
+			},
+			wantConfig: yamlConfig{
+				Host:              defaultHost,
+				Port:              defaultPort,
+				CacheAge:          defaultCacheAge,
+				ReadTimeout:       defaultTimeout,
+				ReadHeaderTimeout: defaultTimeout,
+				WriteTimeout:      defaultTimeout,
+				IdleTimeout:       defaultTimeout,
+				MaxHeaderBytes:    http.DefaultMaxHeaderBytes,
+				MaxPathLength:     defaultMaxPathLength,
+				MaxSubpathDepth:   defaultMaxSubpathDepth,
+				ShutdownTimeout:   30 * time.Second,
+				ShutdownDelay:     5 * time.Second,
+			},
+		},
+		{
+			name: "negative_shutdown_delay",
+			args: []string{"-config", filepath.Join(tmpDir, "negative_shutdown_delay.yml")},
+			files: map[string]string{
+				"negative_shutdown_delay.yml": `shutdown_delay: -5s`,
+			},
+			wantErr: true,
+		},
+		{
+			name: "limits",
+			args: []string{"-config", filepath.Join(tmpDir, "limits.yml")},
+			files: map[string]string{
+				"limits.yml": strings.Join([]string{
+					`read_timeout: 10s`,
+					`read_header_timeout: 2s`,
+					`write_timeout: 15s`,
+					`idle_timeout: 1m`,
+					`max_header_bytes: 4096`,
+					`max_path_length: 256`,
+					`max_subpath_depth: 8`,
+				}, "\n"),
+			},
+			wantConfig: yamlConfig{
+				Host:              defaultHost,
+				Port:              defaultPort,
+				CacheAge:          defaultCacheAge,
+				ReadTimeout:       10 * time.Second,
+				ReadHeaderTimeout: 2 * time.Second,
+				WriteTimeout:      15 * time.Second,
+				IdleTimeout:       time.Minute,
+				MaxHeaderBytes:    4096,
+				MaxPathLength:     256,
+				MaxSubpathDepth:   8,
+				ShutdownTimeout:   defaultShutdownTimeout,
+			},
+		},
+		{
+			name: "negative_limit",
+			args: []string{"-config", filepath.Join(tmpDir, "negative_limit.yml")},
+			files: map[string]string{
+				"negative_limit.yml": `max_path_length: -1`,
+			},
+			wantErr: true,
+		},
+		{
+			name: "short_read_timeout",
+			args: []string{"-config", filepath.Join(tmpDir, "short_read_timeout.yml")},
+			files: map[string]string{
+				"short_read_timeout.yml": `read_timeout: 2s`,
+			},
+			wantConfig: yamlConfig{
+				Host:              defaultHost,
+				Port:              defaultPort,
+				CacheAge:          defaultCacheAge,
+				ReadTimeout:       2 * time.Second,
+				ReadHeaderTimeout: 2 * time.Second,
+				WriteTimeout:      defaultTimeout,
+				IdleTimeout:       defaultTimeout,
+				MaxHeaderBytes:    http.DefaultMaxHeaderBytes,
+				MaxPathLength:     defaultMaxPathLength,
+				MaxSubpathDepth:   defaultMaxSubpathDepth,
+				ShutdownTimeout:   defaultShutdownTimeout,
+			},
+		},
+		{
+			name: "read_header_timeout_exceeds_read_timeout",
+			args: []string{"-config", filepath.Join(tmpDir, "read_header_timeout.yml")},
+			files: map[string]string{
+				"read_header_timeout.yml": strings.Join([]string{
+					`read_timeout: 1s`,
+					`read_header_timeout: 2s`,
+				}, "\n"),
+			},
+			wantErr: true,
+		},
+		{
+			name: "tls",
+			args: []string{"-config", filepath.Join(tmpDir, "tls.yml")},
+			files: map[string]string{
+				"tls.yml": strings.Join([]string{
+					`tls:`,
+					`  cert_file: /etc/ssl/cert.pem`,
+					`  key_file: /etc/ssl/key.pem`,
+					`  redirect_port: 80`,
+				}, "\n"),
+			},
+			wantConfig: yamlConfig{
+				Host:              defaultHost,
+				Port:              defaultPort,
+				CacheAge:          defaultCacheAge,
+				ReadTimeout:       defaultTimeout,
+				ReadHeaderTimeout: defaultTimeout,
+				WriteTimeout:      defaultTimeout,
+				IdleTimeout:       defaultTimeout,
+				MaxHeaderBytes:    http.DefaultMaxHeaderBytes,
+				MaxPathLength:     defaultMaxPathLength,
+				MaxSubpathDepth:   defaultMaxSubpathDepth,
+				TLS: yamlTLS{
+					CertFile:     "/etc/ssl/cert.pem",
+					KeyFile:      "/etc/ssl/key.pem",
+					MinVersion:   defaultTLSMinVersion,
+					RedirectPort: 80,
+				},
+				ShutdownTimeout: defaultShutdownTimeout,
+			},
+		},
+		{
+			name: "tls_missing_key",
+			args: []string{"-config", filepath.Join(tmpDir, "tls_missing_key.yml")},
+			files: map[string]string{
+				"tls_missing_key.yml": strings.Join([]string{
+					`tls:`,
+					`  cert_file: /etc/ssl/cert.pem`,
+				}, "\n"),
+			},
+			wantErr: true,
+		},
+		{
+			name: "tls_invalid_min_version",
+			args: []string{"-config", filepath.Join(tmpDir, "tls_invalid_min_version.yml")},
+			files: map[string]string{
+				"tls_invalid_min_version.yml": strings.Join([]string{
+					`tls:`,
+					`  cert_file: /etc/ssl/cert.pem`,
+					`  key_file: /etc/ssl/key.pem`,
+					`  min_version: "2.0"`,
+				}, "\n"),
+			},
+			wantErr: true,
+		},
+		{
+			name: "tls_acme",
+			args: []string{"-config", filepath.Join(tmpDir, "tls_acme.yml")},
+			files: map[string]string{
+				"tls_acme.yml": strings.Join([]string{
+					`host: go.example.dev`,
+					`tls:`,
+					`  acme:`,
+					`    enabled: true`,
+					`    email: ops@example.dev`,
+					`    directory_url: https://localhost:14000/dir`,
+				}, "\n"),
+			},
+			wantConfig: yamlConfig{
+				Host:              "go.example.dev",
+				Port:              defaultPort,
+				CacheAge:          defaultCacheAge,
+				ReadTimeout:       defaultTimeout,
+				ReadHeaderTimeout: defaultTimeout,
+				WriteTimeout:      defaultTimeout,
+				IdleTimeout:       defaultTimeout,
+				MaxHeaderBytes:    http.DefaultMaxHeaderBytes,
+				MaxPathLength:     defaultMaxPathLength,
+				MaxSubpathDepth:   defaultMaxSubpathDepth,
+				TLS: yamlTLS{
+					MinVersion: defaultTLSMinVersion,
+					ACME: yamlACME{
+						Enabled:      true,
+						Hosts:        []string{"go.example.dev"},
+						Email:        "ops@example.dev",
+						CacheDir:     defaultACMECacheDir,
+						DirectoryURL: "https://localhost:14000/dir",
+						Challenge:    acmeChallengeTLSALPN,
 					},
 				},
+				ShutdownTimeout: defaultShutdownTimeout,
+			},
+		},
+		{
+			name: "tls_acme_missing_hosts",
+			args: []string{"-config", filepath.Join(tmpDir, "tls_acme_missing_hosts.yml")},
+			files: map[string]string{
+				"tls_acme_missing_hosts.yml": strings.Join([]string{
+					`tls:`,
+					`  acme:`,
+					`    enabled: true`,
+				}, "\n"),
+			},
+			wantErr: true,
+		},
+		{
+			name: "tls_acme_http_challenge_without_redirect",
+			args: []string{"-config", filepath.Join(tmpDir, "tls_acme_http_challenge.yml")},
+			files: map[string]string{
+				"tls_acme_http_challenge.yml": strings.Join([]string{
+					`host: go.example.dev`,
+					`tls:`,
+					`  acme:`,
+					`    enabled: true`,
+					`    challenge: http-01`,
+				}, "\n"),
+			},
+			wantErr: true,
+		},
+		{
+			name: "tls_acme_with_cert_file",
+			args: []string{"-config", filepath.Join(tmpDir, "tls_acme_with_cert_file.yml")},
+			files: map[string]string{
+				"tls_acme_with_cert_file.yml": strings.Join([]string{
+					`host: go.example.dev`,
+					`tls:`,
+					`  cert_file: /etc/ssl/cert.pem`,
+					`  key_file: /etc/ssl/key.pem`,
+					`  acme:`,
+					`    enabled: true`,
+				}, "\n"),
 			},
+			wantErr: true,
 		},
 		{
 			name: "malformed",
@@ -221,7 +854,7 @@ func Test_parseConfig(t *testing.T) {
 				}
 			}
 
-			got, err := parseConfig(tc.args)
+			got, err := parseConfig(tc.args, &stringValue{})
 
 			if tc.wantErr != (err != nil) {
 				t.Errorf("parseConfig() = %v; wantErr = %v", err, tc.wantErr)
@@ -234,12 +867,105 @@ func Test_parseConfig(t *testing.T) {
 	}
 }
 
+func Test_checkReservedPaths(t *testing.T) {
+	packages := []vanityurl.Package{
+		{
+			Path:          "/foo",
+			RepositoryURL: "https://github.com/example/foo",
+		},
+		{
+			Path:          "/later",
+			RepositoryURL: "https://github.com/example/later",
+			PublishAt:     time.Now().Add(time.Hour),
+		},
+	}
+
+	tt := []struct {
+		name    string
+		paths   []string
+		wantErr bool
+	}{
+		{
+			name:  "no_collision",
+			paths: []string{"/healthz", "/readyz"},
+		},
+		{
+			name:    "package_path",
+			paths:   []string{"/healthz", "/foo"},
+			wantErr: true,
+		},
+		{
+			name:    "package_subpath",
+			paths:   []string{"/foo/healthz"},
+			wantErr: true,
+		},
+		{
+			name:    "package_published_later",
+			paths:   []string{"/later/healthz"},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkReservedPaths(packages, tc.paths)
+			if tc.wantErr != (err != nil) {
+				t.Errorf("checkReservedPaths() = %v; wantErr = %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func Test_loadTemplates(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	valid := filepath.Join(tmpDir, "valid")
+	invalid := filepath.Join(tmpDir, "invalid")
+
+	for dir, contents := range map[string]string{
+		valid:   `<html>{{template "head" .}}<body>Custom</body></html>`,
+		invalid: `<html>{{.Unknown}}</html>`,
+	} {
+		if err := os.Mkdir(dir, 0o700); err != nil {
+			t.Fatalf("failed to create templates dir: %v", err)
+		}
+
+		if err := os.WriteFile(filepath.Join(dir, "document.gohtml"), []byte(contents), 0o600); err != nil {
+			t.Fatalf("failed to create template: %v", err)
+		}
+	}
+
+	tt := []struct {
+		name    string
+		dir     string
+		wantErr bool
+	}{
+		{name: "default", dir: ""},
+		{name: "valid", dir: valid},
+		{name: "invalid", dir: invalid, wantErr: true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			templates, err := loadTemplates(tc.dir)
+			if tc.wantErr != (err != nil) {
+				t.Errorf("loadTemplates() = %v; wantErr = %v", err, tc.wantErr)
+			}
+
+			if !tc.wantErr && templates == nil {
+				t.Errorf("loadTemplates() = nil; want templates")
+			}
+		})
+	}
+}
+
 func Test_parseFlag(t *testing.T) {
 	tt := []struct {
 		name      string
 		args      []string
 		cfgName   stringValue
 		wantValue stringValue
+		wantMode  stringValue
 		wantErr   bool
 	}{
 		{
@@ -266,6 +992,22 @@ func Test_parseFlag(t *testing.T) {
 				set:   true,
 			},
 		},
+		{
+			name: "mode",
+			args: []string{"-mode", "fcgi"},
+			cfgName: stringValue{
+				value: "foo.yml",
+			},
+			wantErr: false,
+			wantValue: stringValue{
+				value: "foo.yml",
+				set:   false,
+			},
+			wantMode: stringValue{
+				value: "fcgi",
+				set:   true,
+			},
+		},
 		{
 			name: "unknown_flag",
 			args: []string{"-unknown"},
@@ -281,7 +1023,9 @@ func Test_parseFlag(t *testing.T) {
 	}
 	for _, tc := range tt {
 		t.Run(tc.name, func(t *testing.T) {
-			err := parseFlags(tc.args, &tc.cfgName)
+			var mode stringValue
+
+			err := parseFlags(tc.args, &tc.cfgName, &mode)
 			if tc.wantErr != (err != nil) {
 				t.Errorf("parseFlag() = %v; wantErr = %v", err, tc.wantErr)
 			}
@@ -289,6 +1033,10 @@ func Test_parseFlag(t *testing.T) {
 			if tc.wantValue != tc.cfgName {
 				t.Errorf("cfgName = %v; want = %v", tc.cfgName, tc.wantValue)
 			}
+
+			if tc.wantMode != mode {
+				t.Errorf("mode = %v; want = %v", mode, tc.wantMode)
+			}
 		})
 	}
 }
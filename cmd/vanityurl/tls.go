@@ -0,0 +1,196 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//nolint:gochecknoglobals
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+const defaultTLSMinVersion = "1.2"
+
+type yamlTLS struct {
+	CertFile     string `yaml:"cert_file"`
+	KeyFile      string `yaml:"key_file"`
+	MinVersion   string `yaml:"min_version"`
+	RedirectPort uint   `yaml:"redirect_port"`
+
+	ACME yamlACME `yaml:"acme"`
+}
+
+// Enabled reports whether TLS is configured.
+func (t yamlTLS) Enabled() bool {
+	return t.CertFile != "" || t.KeyFile != "" || t.ACME.Enabled
+}
+
+func (t yamlTLS) validate() error {
+	if !t.Enabled() {
+		if t.RedirectPort != 0 {
+			return fmt.Errorf("invalid tls redirect_port %d: requires cert_file and key_file or acme", t.RedirectPort)
+		}
+
+		return nil
+	}
+
+	if t.ACME.Enabled {
+		if t.CertFile != "" || t.KeyFile != "" {
+			return errors.New("invalid tls config: cert_file and key_file can not be used with acme")
+		}
+	} else if t.CertFile == "" || t.KeyFile == "" {
+		return errors.New("invalid tls config: both cert_file and key_file are required")
+	}
+
+	if _, ok := tlsVersions[t.MinVersion]; !ok {
+		return fmt.Errorf("invalid tls min_version %q: must be one of 1.0, 1.1, 1.2, 1.3", t.MinVersion)
+	}
+
+	return t.ACME.validate(t.RedirectPort)
+}
+
+// certReloader loads certificate from files and reloads it when files are modified.
+type certReloader struct {
+	certFile string
+	keyFile  string
+	logger   *slog.Logger
+
+	mu       sync.Mutex
+	cert     *tls.Certificate
+	modTimes [2]time.Time
+}
+
+func newCertReloader(certFile, keyFile string, logger *slog.Logger) (*certReloader, error) {
+	r := &certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		logger:   logger,
+	}
+
+	modTimes, err := r.stat()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.load(modTimes); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// GetCertificate implementation for [tls.Config].
+// Keeps serving previous certificate if files can not be reloaded.
+func (r *certReloader) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	modTimes, err := r.stat()
+	if err != nil {
+		r.logger.Error("Failed to check certificate files", "err", err)
+
+		return r.cert, nil
+	}
+
+	if modTimes != r.modTimes {
+		if err := r.load(modTimes); err != nil {
+			r.logger.Error("Failed to reload certificate", "err", err)
+		} else {
+			r.logger.Info("Reloaded certificate", "cert_file", r.certFile)
+		}
+	}
+
+	return r.cert, nil
+}
+
+func (r *certReloader) load(modTimes [2]time.Time) error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load certificate: %w", err)
+	}
+
+	r.cert = &cert
+	r.modTimes = modTimes
+
+	return nil
+}
+
+func (r *certReloader) stat() ([2]time.Time, error) {
+	var modTimes [2]time.Time
+
+	for i, name := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return modTimes, err
+		}
+
+		modTimes[i] = info.ModTime()
+	}
+
+	return modTimes, nil
+}
+
+// newTLSConfig with certificates reloaded from configured files.
+func newTLSConfig(cfg yamlTLS, logger *slog.Logger) (*tls.Config, error) {
+	reloader, err := newCertReloader(cfg.CertFile, cfg.KeyFile, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		MinVersion:     tlsVersions[cfg.MinVersion],
+		GetCertificate: reloader.GetCertificate,
+	}, nil
+}
+
+// redirectPort returns port of TLS listeners to redirect plain HTTP requests to. Listeners must use
+// exactly one TCP port, so the redirect target is not ambiguous.
+func redirectPort(listeners []listener) (int, error) {
+	var ports []int
+
+	for _, l := range listeners {
+		addr, ok := l.Addr().(*net.TCPAddr)
+		if l.admin || !ok || slices.Contains(ports, addr.Port) {
+			continue
+		}
+
+		ports = append(ports, addr.Port)
+	}
+
+	if len(ports) != 1 {
+		return 0, fmt.Errorf("invalid tls redirect_port: requires TLS listeners on exactly one TCP port, got %v", ports)
+	}
+
+	return ports[0], nil
+}
+
+// redirectHandler redirects plain HTTP requests to HTTPS on a given port.
+func redirectHandler(httpsPort int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = strings.TrimSuffix(strings.TrimPrefix(r.Host, "["), "]")
+		}
+
+		// IPv6 hosts are kept in brackets, and default port is left out
+		host = strings.TrimSuffix(net.JoinHostPort(host, strconv.Itoa(httpsPort)), ":443")
+
+		target := "https://" + host + r.URL.RequestURI()
+
+		http.Redirect(w, r, target, http.StatusMovedPermanently)
+	})
+}
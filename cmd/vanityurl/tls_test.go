@@ -0,0 +1,212 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeTestCert(t testing.TB, certFile, keyFile, commonName string, modTime time.Time) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+
+	files := map[string][]byte{
+		certFile: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyFile:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+
+	for name, contents := range files {
+		if err := os.WriteFile(name, contents, 0o600); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+
+		if err := os.Chtimes(name, modTime, modTime); err != nil {
+			t.Fatalf("failed to change mod time of %s: %v", name, err)
+		}
+	}
+}
+
+func Test_certReloader(t *testing.T) {
+	tmpDir := t.TempDir()
+	certFile := filepath.Join(tmpDir, "cert.pem")
+	keyFile := filepath.Join(tmpDir, "key.pem")
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	now := time.Now()
+
+	writeTestCert(t, certFile, keyFile, "old.example.com", now.Add(-time.Minute))
+
+	reloader, err := newCertReloader(certFile, keyFile, logger)
+	if err != nil {
+		t.Fatalf("newCertReloader() = %v", err)
+	}
+
+	wantCommonName := func(want string) {
+		t.Helper()
+
+		cert, err := reloader.GetCertificate(&tls.ClientHelloInfo{})
+		if err != nil {
+			t.Fatalf("certReloader.GetCertificate() = %v", err)
+		}
+
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			t.Fatalf("failed to parse certificate: %v", err)
+		}
+
+		if leaf.Subject.CommonName != want {
+			t.Errorf("certReloader.GetCertificate() CommonName = %s; want = %s", leaf.Subject.CommonName, want)
+		}
+	}
+
+	wantCommonName("old.example.com")
+
+	writeTestCert(t, certFile, keyFile, "new.example.com", now)
+	wantCommonName("new.example.com")
+
+	if err := os.WriteFile(keyFile, []byte("broken"), 0o600); err != nil {
+		t.Fatalf("failed to break key file: %v", err)
+	}
+
+	wantCommonName("new.example.com")
+
+	if _, err := newCertReloader(certFile, filepath.Join(tmpDir, "missing.pem"), logger); err == nil {
+		t.Errorf("newCertReloader() = nil; want error for missing key file")
+	}
+}
+
+func Test_redirectHandler(t *testing.T) {
+	tt := []struct {
+		name      string
+		httpsPort int
+		target    string
+		want      string
+	}{
+		{
+			name:      "default_port",
+			httpsPort: 443,
+			target:    "http://go.example.com:80/foo/bar?go-get=1",
+			want:      "https://go.example.com/foo/bar?go-get=1",
+		},
+		{
+			name:      "custom_port",
+			httpsPort: 8443,
+			target:    "http://go.example.com/foo",
+			want:      "https://go.example.com:8443/foo",
+		},
+		{
+			name:      "ipv6_default_port",
+			httpsPort: 443,
+			target:    "http://[::1]:80/foo",
+			want:      "https://[::1]/foo",
+		},
+		{
+			name:      "ipv6_custom_port",
+			httpsPort: 8443,
+			target:    "http://[::1]/foo",
+			want:      "https://[::1]:8443/foo",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			redirectHandler(tc.httpsPort).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.target, nil))
+
+			if rec.Code != http.StatusMovedPermanently {
+				t.Errorf("redirectHandler() status = %d; want = %d", rec.Code, http.StatusMovedPermanently)
+			}
+
+			if got := rec.Header().Get("Location"); got != tc.want {
+				t.Errorf("redirectHandler() Location = %s; want = %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func Test_redirectPort(t *testing.T) {
+	listenLocal := func(admin bool) listener {
+		t.Helper()
+
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("failed to listen: %v", err)
+		}
+
+		t.Cleanup(func() { _ = l.Close() })
+
+		return listener{Listener: l, admin: admin}
+	}
+
+	public, other, admin := listenLocal(false), listenLocal(false), listenLocal(true)
+
+	unix, err := net.Listen("unix", filepath.Join(t.TempDir(), "vanityurl.sock"))
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	t.Cleanup(func() { _ = unix.Close() })
+
+	tt := []struct {
+		name      string
+		listeners []listener
+		want      int
+		wantErr   bool
+	}{
+		{
+			name:      "single",
+			listeners: []listener{public, admin, {Listener: unix}},
+			want:      public.Addr().(*net.TCPAddr).Port,
+		},
+		{name: "ambiguous", listeners: []listener{public, other}, wantErr: true},
+		{name: "no_tcp", listeners: []listener{admin, {Listener: unix}}, wantErr: true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := redirectPort(tc.listeners)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("redirectPort() = %v; wantErr = %v", err, tc.wantErr)
+			}
+
+			if got != tc.want {
+				t.Errorf("redirectPort() = %d; want = %d", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package vanityurl
+
+import (
+	"fmt"
+	"time"
+)
+
+// PackageConfig is a [Package] entry of YAML config, with VCS and visibility given by name.
+// It is shared by the vanityurl command and package awslambda, so both read the same keys.
+type PackageConfig struct {
+	Path          string            `yaml:"path"`
+	VCS           string            `yaml:"vcs"`
+	Display       string            `yaml:"display"`
+	RepositoryURL string            `yaml:"repository_url"`
+	MovedTo       string            `yaml:"moved_to"`
+	Retired       bool              `yaml:"retired"`
+	RetiredReason string            `yaml:"retired_reason"`
+	Aliases       []string          `yaml:"aliases"`
+	Canonical     string            `yaml:"canonical"`
+	CacheAge      time.Duration     `yaml:"cache_age"`
+	Headers       map[string]string `yaml:"headers"`
+	Description   string            `yaml:"description"`
+	Homepage      string            `yaml:"homepage"`
+	License       string            `yaml:"license"`
+	Owner         string            `yaml:"owner"`
+	Tags          []string          `yaml:"tags"`
+	Visibility    string            `yaml:"visibility"`
+	PublishAt     time.Time         `yaml:"publish_at"`
+	ExpireAt      time.Time         `yaml:"expire_at"`
+}
+
+// Package converts config entry to [Package]. Empty VCS is detected from repository URL and
+// empty visibility is [Public]. Returns [ErrInvalidVCS] or [ErrInvalidVisibility] for unknown names.
+func (cfg PackageConfig) Package() (Package, error) {
+	var vcs VCS
+
+	if cfg.VCS != "" {
+		parsed, err := ParseVCS(cfg.VCS)
+		if err != nil {
+			return Package{}, fmt.Errorf("%w: %s", err, cfg.VCS)
+		}
+
+		vcs = parsed
+	}
+
+	visibility := Public
+
+	if cfg.Visibility != "" {
+		parsed, err := ParseVisibility(cfg.Visibility)
+		if err != nil {
+			return Package{}, fmt.Errorf("%w: %s", err, cfg.Visibility)
+		}
+
+		visibility = parsed
+	}
+
+	return Package{
+		Path:          cfg.Path,
+		VCS:           vcs,
+		Display:       cfg.Display,
+		RepositoryURL: cfg.RepositoryURL,
+		MovedTo:       cfg.MovedTo,
+		Retired:       cfg.Retired,
+		RetiredReason: cfg.RetiredReason,
+		Aliases:       cfg.Aliases,
+		Canonical:     cfg.Canonical,
+		CacheAge:      cfg.CacheAge,
+		Headers:       cfg.Headers,
+		Description:   cfg.Description,
+		Homepage:      cfg.Homepage,
+		License:       cfg.License,
+		Owner:         cfg.Owner,
+		Tags:          cfg.Tags,
+		Visibility:    visibility,
+		PublishAt:     cfg.PublishAt,
+		ExpireAt:      cfg.ExpireAt,
+	}, nil
+}
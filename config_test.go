@@ -0,0 +1,64 @@
+package vanityurl_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"go.wamod.dev/vanityurl"
+)
+
+func TestPackageConfigPackage(t *testing.T) {
+	tt := []struct {
+		name    string
+		cfg     vanityurl.PackageConfig
+		want    vanityurl.Package
+		wantErr error
+	}{
+		{
+			name: "defaults",
+			cfg:  vanityurl.PackageConfig{Path: "/foo", RepositoryURL: "https://github.com/example/foo"},
+			want: vanityurl.Package{Path: "/foo", RepositoryURL: "https://github.com/example/foo"},
+		},
+		{
+			name: "named",
+			cfg: vanityurl.PackageConfig{
+				Path:          "/foo",
+				VCS:           "hg",
+				RepositoryURL: "https://hg.example.com/foo",
+				Visibility:    "unlisted",
+				Tags:          []string{"cli"},
+			},
+			want: vanityurl.Package{
+				Path:          "/foo",
+				VCS:           vanityurl.Mercurial,
+				RepositoryURL: "https://hg.example.com/foo",
+				Visibility:    vanityurl.Unlisted,
+				Tags:          []string{"cli"},
+			},
+		},
+		{
+			name:    "invalid_vcs",
+			cfg:     vanityurl.PackageConfig{Path: "/foo", VCS: "cvs"},
+			wantErr: vanityurl.ErrInvalidVCS,
+		},
+		{
+			name:    "invalid_visibility",
+			cfg:     vanityurl.PackageConfig{Path: "/foo", Visibility: "hidden"},
+			wantErr: vanityurl.ErrInvalidVisibility,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.cfg.Package()
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("PackageConfig.Package() = %v; wantErr = %v", err, tc.wantErr)
+			}
+
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("PackageConfig.Package() = %+v; want = %+v", got, tc.want)
+			}
+		})
+	}
+}
@@ -5,7 +5,12 @@ import (
 )
 
 var (
-	ErrPackageNotFound = fmt.Errorf("vanityurl: package not found")
-	ErrInvalidPackage  = fmt.Errorf("vanityurl: invalid package")
-	ErrInvalidVCS      = fmt.Errorf("vanityurl: invalid vcs")
+	ErrPackageNotFound   = fmt.Errorf("vanityurl: package not found")
+	ErrPackageGone       = fmt.Errorf("vanityurl: package gone")
+	ErrInvalidPackage    = fmt.Errorf("vanityurl: invalid package")
+	ErrInvalidVCS        = fmt.Errorf("vanityurl: invalid vcs")
+	ErrInvalidVisibility = fmt.Errorf("vanityurl: invalid visibility")
+	ErrServerNotReady    = fmt.Errorf("vanityurl: server not ready")
+	ErrInvalidTemplate   = fmt.Errorf("vanityurl: invalid template")
+	ErrInvalidAuth       = fmt.Errorf("vanityurl: invalid auth")
 )
@@ -2,4 +2,12 @@ module go.wamod.dev/vanityurl
 
 go 1.22
 
-require gopkg.in/yaml.v3 v3.0.1
+require (
+	golang.org/x/crypto v0.33.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	golang.org/x/net v0.21.0 // indirect
+	golang.org/x/text v0.22.0 // indirect
+)
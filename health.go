@@ -0,0 +1,94 @@
+package vanityurl
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+)
+
+// HealthChecker can be implemented by [Resolver] to report its health.
+// Server readiness endpoint fails when CheckHealth returns an error.
+type HealthChecker interface {
+	// CheckHealth returns an error if resolver is not able to resolve packages.
+	CheckHealth(ctx context.Context) error
+}
+
+// SetReady changes readiness state reported by the readiness endpoint.
+// Server is ready by default, set it to false before shutting down.
+func (srv *Server) SetReady(ready bool) {
+	srv.notReady.Store(!ready)
+}
+
+// Ready reports whether server is ready to serve requests.
+func (srv *Server) Ready(ctx context.Context) error {
+	if srv.notReady.Load() {
+		return ErrServerNotReady
+	}
+
+	if checker, ok := srv.resolver.(HealthChecker); ok {
+		return checker.CheckHealth(ctx)
+	}
+
+	return nil
+}
+
+// ReservedPaths returns paths of enabled server endpoints, which are never resolved as packages.
+func (srv *Server) ReservedPaths() []string {
+	paths := make([]string, 0, len(srv.reserved))
+
+	for path := range srv.reserved {
+		paths = append(paths, path)
+	}
+
+	slices.Sort(paths)
+
+	return paths
+}
+
+func (srv *Server) serveHealth(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Add("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Add("Cache-Control", "no-store")
+
+	_, _ = w.Write([]byte("OK\n"))
+}
+
+func (srv *Server) serveReady(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Cache-Control", "no-store")
+
+	if err := srv.Ready(r.Context()); err != nil {
+		http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+
+		return
+	}
+
+	w.Header().Add("Content-Type", "text/plain; charset=utf-8")
+
+	_, _ = w.Write([]byte("OK\n"))
+}
+
+func (srv *Server) serveVersion(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	w.Header().Add("Cache-Control", "no-store")
+
+	_ = json.NewEncoder(w).Encode(struct {
+		Version string `json:"version"`
+	}{
+		Version: srv.version,
+	})
+}
+
+func (r *multiResolver) CheckHealth(ctx context.Context) error {
+	for _, rr := range r.rset {
+		checker, ok := rr.(HealthChecker)
+		if !ok {
+			continue
+		}
+
+		if err := checker.CheckHealth(ctx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
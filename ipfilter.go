@@ -0,0 +1,81 @@
+package vanityurl
+
+import (
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+// IPRule allows or denies clients by IP address for request paths under a prefix.
+type IPRule struct {
+	// Prefix of request paths, e.g. "/internal". Rule applies to all paths if empty.
+	Prefix string
+	// Allow only clients in these networks. All clients are allowed if empty.
+	Allow []netip.Prefix
+	// Deny clients in these networks, even if allowed.
+	Deny []netip.Prefix
+}
+
+// applies reports whether rule applies to request path.
+func (rule IPRule) applies(path string) bool {
+	prefix := strings.TrimSuffix(rule.Prefix, "/")
+
+	return prefix == "" || path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// allows reports whether client address passes the rule. Unknown address passes deny-only rules.
+func (rule IPRule) allows(addr netip.Addr) bool {
+	if addr.IsValid() && isTrusted(addr, rule.Deny) {
+		return false
+	}
+
+	return len(rule.Allow) == 0 || (addr.IsValid() && isTrusted(addr, rule.Allow))
+}
+
+// allowIP checks client address against every rule applying to any of request paths, so global
+// rules and rules of each matching prefix all have to pass. Denied requests are logged.
+func (srv *Server) allowIP(r *http.Request, paths []string) bool {
+	if len(srv.ipRules) == 0 {
+		return true
+	}
+
+	addr := clientIP(r, srv.trustedProxies)
+
+	for _, rule := range srv.ipRules {
+		if !slices.ContainsFunc(paths, rule.applies) {
+			continue
+		}
+
+		if !rule.allows(addr) {
+			srv.logger.LogAttrs(r.Context(), slog.LevelWarn, "Denied client IP",
+				slog.String("path", r.URL.Path),
+				slog.String("remote_ip", addr.String()),
+				slog.String("rule_prefix", rule.Prefix),
+			)
+
+			return false
+		}
+	}
+
+	srv.logger.LogAttrs(r.Context(), slog.LevelDebug, "Allowed client IP",
+		slog.String("path", r.URL.Path),
+		slog.String("remote_ip", addr.String()),
+	)
+
+	return true
+}
+
+// aliasPaths returns request path resolved to package, and the same subpath under each of
+// package aliases.
+func aliasPaths(pkg Package, path string) []string {
+	rest := strings.TrimPrefix(path, pkg.Path)
+	paths := []string{path}
+
+	for _, alias := range pkg.Aliases {
+		paths = append(paths, alias+rest)
+	}
+
+	return paths
+}
@@ -0,0 +1,149 @@
+package vanityurl_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.wamod.dev/vanityurl"
+)
+
+func TestServerIPRules(t *testing.T) {
+	resolver := mustResolver(t,
+		vanityurl.Package{Path: "/foo", RepositoryURL: "https://github.com/example/foo"},
+		vanityurl.Package{Path: "/internal/bar", RepositoryURL: "https://github.com/example/bar"},
+		vanityurl.Package{Path: "/internal/baz", Aliases: []string{"/baz"}, RepositoryURL: "https://github.com/example/baz"},
+	)
+
+	opts := &vanityurl.ServerOptions{
+		IPRules: []vanityurl.IPRule{
+			{Deny: []netip.Prefix{netip.MustParsePrefix("203.0.113.0/24")}},
+			{Prefix: "/internal", Allow: []netip.Prefix{netip.MustParsePrefix("192.0.2.0/24")}},
+		},
+		TrustedProxies: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+	}
+
+	tt := []struct {
+		name       string
+		target     string
+		remoteAddr string
+		forwarded  string
+		wantStatus int
+		wantLog    bool
+	}{
+		{name: "public_allowed", target: "/foo", remoteAddr: "198.51.100.1:1234", wantStatus: http.StatusOK},
+		{name: "global_denied", target: "/foo", remoteAddr: "203.0.113.1:1234", wantStatus: http.StatusForbidden, wantLog: true},
+		{name: "prefix_allowed", target: "/internal/bar/sub", remoteAddr: "192.0.2.1:1234", wantStatus: http.StatusOK},
+		{name: "prefix_denied", target: "/internal/bar", remoteAddr: "198.51.100.1:1234", wantStatus: http.StatusForbidden, wantLog: true},
+		{name: "prefix_via_proxy", target: "/internal/bar", remoteAddr: "10.0.0.1:1234", forwarded: "for=192.0.2.1", wantStatus: http.StatusOK},
+		{name: "prefix_untrusted_proxy", target: "/internal/bar", remoteAddr: "198.51.100.1:1234", forwarded: "for=192.0.2.1", wantStatus: http.StatusForbidden, wantLog: true},
+		{name: "alias_denied", target: "/baz", remoteAddr: "198.51.100.1:1234", wantStatus: http.StatusForbidden, wantLog: true},
+		{name: "alias_subpath_denied", target: "/baz/sub", remoteAddr: "198.51.100.1:1234", wantStatus: http.StatusForbidden, wantLog: true},
+		{name: "alias_allowed", target: "/baz", remoteAddr: "192.0.2.1:1234", wantStatus: http.StatusOK},
+		{name: "missing_prefix_denied", target: "/internal/missing", remoteAddr: "198.51.100.1:1234", wantStatus: http.StatusForbidden, wantLog: true},
+		{name: "global_denied_nested", target: "/internal/bar", remoteAddr: "10.0.0.1:1234", forwarded: "for=203.0.113.1", wantStatus: http.StatusForbidden, wantLog: true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var logs bytes.Buffer
+
+			opts := *opts
+			opts.Logger = slog.New(slog.NewTextHandler(&logs, nil))
+
+			r := httptest.NewRequest(http.MethodGet, tc.target+"?go-get=1", nil)
+			r.RemoteAddr = tc.remoteAddr
+
+			if tc.forwarded != "" {
+				r.Header.Set("Forwarded", tc.forwarded)
+			}
+
+			w := httptest.NewRecorder()
+
+			vanityurl.NewServer(resolver, &opts).ServeHTTP(w, r)
+
+			if w.Code != tc.wantStatus {
+				t.Errorf("status = %d; want = %d", w.Code, tc.wantStatus)
+			}
+
+			if got := strings.Contains(logs.String(), "Denied client IP"); got != tc.wantLog {
+				t.Errorf("logs = %s; want denied log = %t", logs.String(), tc.wantLog)
+			}
+		})
+	}
+}
+
+// TestServerUnixSocket checks that proxy headers are trusted for clients connected over unix socket,
+// which have no IP address.
+func TestServerUnixSocket(t *testing.T) {
+	resolver := mustResolver(t,
+		vanityurl.Package{Path: "/internal/foo", RepositoryURL: "https://github.com/example/foo"},
+	)
+
+	handler := vanityurl.NewRateLimitHandler(
+		vanityurl.NewServer(resolver, &vanityurl.ServerOptions{
+			IPRules: []vanityurl.IPRule{
+				{Prefix: "/internal", Allow: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}},
+			},
+		}),
+		&vanityurl.RateLimitOptions{GoGet: vanityurl.RateLimit{Rate: 0.001}},
+	)
+
+	socket := filepath.Join(t.TempDir(), "vanityurl.sock")
+
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	httpSrv := &http.Server{Handler: handler, ReadHeaderTimeout: time.Second}
+
+	go func() { _ = httpSrv.Serve(l) }()
+
+	t.Cleanup(func() { _ = httpSrv.Close() })
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}}
+
+	steps := []struct {
+		forwardedFor string
+		wantStatus   int
+	}{
+		{forwardedFor: "10.1.2.3", wantStatus: http.StatusOK},
+		{forwardedFor: "203.0.113.5", wantStatus: http.StatusForbidden},
+		// Clients get own buckets instead of sharing one of the socket
+		{forwardedFor: "10.1.2.4", wantStatus: http.StatusOK},
+		{forwardedFor: "10.1.2.3", wantStatus: http.StatusTooManyRequests},
+	}
+
+	for i, step := range steps {
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "http://vanityurl/internal/foo?go-get=1", nil)
+		if err != nil {
+			t.Fatalf("got error while creating request: %v", err)
+		}
+
+		req.Header.Set("X-Forwarded-For", step.forwardedFor)
+
+		res, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("got error while making request: %v", err)
+		}
+
+		_ = res.Body.Close()
+
+		if res.StatusCode != step.wantStatus {
+			t.Errorf("step %d: status = %d; want = %d", i, res.StatusCode, step.wantStatus)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package vanityurl
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	clientGoGet   = "go-get"
+	clientBrowser = "browser"
+
+	resolveFound    = "found"
+	resolveNotFound = "not_found"
+	resolveGone     = "gone"
+	resolveError    = "error"
+
+	// hiddenPackage is package label of unlisted and private packages, so their paths are not revealed.
+	hiddenPackage = "hidden"
+)
+
+//nolint:gochecknoglobals
+var resolveBuckets = []float64{.0001, .0005, .001, .005, .01, .05, .1, .5, 1, 5}
+
+type requestKey struct {
+	code   int
+	client string
+}
+
+type packageKey struct {
+	path   string
+	client string
+}
+
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// metrics of [Server] requests written in Prometheus text exposition format.
+type metrics struct {
+	mu       sync.Mutex
+	requests map[requestKey]uint64
+	packages map[packageKey]uint64
+	resolves map[string]*histogram
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		requests: map[requestKey]uint64{},
+		packages: map[packageKey]uint64{},
+		resolves: map[string]*histogram{},
+	}
+}
+
+func (m *metrics) observeResolve(d time.Duration, err error) {
+	if m == nil {
+		return
+	}
+
+	result := resolveFound
+	if errors.Is(err, ErrPackageNotFound) {
+		result = resolveNotFound
+	} else if errors.Is(err, ErrPackageGone) {
+		result = resolveGone
+	} else if err != nil {
+		result = resolveError
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	h, ok := m.resolves[result]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(resolveBuckets))}
+		m.resolves[result] = h
+	}
+
+	seconds := d.Seconds()
+
+	for i, le := range resolveBuckets {
+		if seconds <= le {
+			h.counts[i]++
+		}
+	}
+
+	h.count++
+	h.sum += seconds
+}
+
+// observeRequest counts request by status code and client type.
+// Package path is counted only if not empty, so label values are limited to resolved packages.
+func (m *metrics) observeRequest(code int, client, pkgPath string) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests[requestKey{code, client}]++
+
+	if pkgPath != "" {
+		m.packages[packageKey{pkgPath, client}]++
+	}
+}
+
+// WriteTo writes metrics in Prometheus text exposition format.
+func (m *metrics) WriteTo(wr io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var b strings.Builder
+
+	b.WriteString("# HELP vanityurl_requests_total Total number of package requests by status code and client.\n")
+	b.WriteString("# TYPE vanityurl_requests_total counter\n")
+
+	requestKeys := sortedKeys(m.requests, func(a, b requestKey) int {
+		return cmpKeys(a.code-b.code, a.client, b.client)
+	})
+
+	for _, k := range requestKeys {
+		fmt.Fprintf(&b, "vanityurl_requests_total{code=\"%d\",client=\"%s\"} %d\n", k.code, k.client, m.requests[k])
+	}
+
+	b.WriteString("# HELP vanityurl_package_requests_total Total number of requests by resolved package and client.\n")
+	b.WriteString("# TYPE vanityurl_package_requests_total counter\n")
+
+	packageKeys := sortedKeys(m.packages, func(a, b packageKey) int {
+		return cmpKeys(strings.Compare(a.path, b.path), a.client, b.client)
+	})
+
+	for _, k := range packageKeys {
+		fmt.Fprintf(&b, "vanityurl_package_requests_total{package=\"%s\",client=\"%s\"} %d\n",
+			escapeLabel(k.path), k.client, m.packages[k])
+	}
+
+	b.WriteString("# HELP vanityurl_resolve_duration_seconds Resolver latency by result.\n")
+	b.WriteString("# TYPE vanityurl_resolve_duration_seconds histogram\n")
+
+	results := sortedKeys(m.resolves, strings.Compare)
+
+	for _, result := range results {
+		h := m.resolves[result]
+
+		for i, le := range resolveBuckets {
+			fmt.Fprintf(&b, "vanityurl_resolve_duration_seconds_bucket{result=\"%s\",le=\"%s\"} %d\n",
+				result, strconv.FormatFloat(le, 'g', -1, 64), h.counts[i])
+		}
+
+		fmt.Fprintf(&b, "vanityurl_resolve_duration_seconds_bucket{result=\"%s\",le=\"+Inf\"} %d\n", result, h.count)
+		fmt.Fprintf(&b, "vanityurl_resolve_duration_seconds_sum{result=\"%s\"} %s\n",
+			result, strconv.FormatFloat(h.sum, 'g', -1, 64))
+		fmt.Fprintf(&b, "vanityurl_resolve_duration_seconds_count{result=\"%s\"} %d\n", result, h.count)
+	}
+
+	n, err := io.WriteString(wr, b.String())
+
+	return int64(n), err
+}
+
+func (srv *Server) serveMetrics(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Add("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Header().Add("Cache-Control", "no-store")
+
+	_, _ = srv.metrics.WriteTo(w)
+}
+
+func sortedKeys[K comparable, V any](m map[K]V, cmp func(a, b K) int) []K {
+	keys := make([]K, 0, len(m))
+
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	slices.SortFunc(keys, cmp)
+
+	return keys
+}
+
+func cmpKeys(first int, a, b string) int {
+	if first != 0 {
+		return first
+	}
+
+	return strings.Compare(a, b)
+}
+
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
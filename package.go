@@ -9,11 +9,7 @@ import (
 	"slices"
 	"strings"
 	"text/template"
-)
-
-const (
-	pkgHeadName = "head"
-	pkgDocName  = "document"
+	"time"
 )
 
 //nolint:gochecknoglobals
@@ -30,41 +26,122 @@ type Package struct {
 	VCS           VCS
 	Display       string
 	RepositoryURL string
+	// MovedTo is a new path of renamed package on the same host. Browsers are redirected to it,
+	// while the go tool still gets meta elements for the old path.
+	MovedTo string
+	// Retired package is answered with 410 Gone and RetiredReason instead of meta elements.
+	Retired       bool
+	RetiredReason string
+	// Aliases are other import paths of the same repository, each rendered with its own prefix.
+	// Resolver serves every alias as a separate package listing the remaining paths as aliases.
+	Aliases []string
+	// Canonical path among Path and Aliases, where browsers are sent to read documentation.
+	// Default is Path.
+	Canonical string
+	// CacheAge for response Cache-Control header, overriding [ServerOptions.CacheAge] if not zero.
+	CacheAge time.Duration
+	// Visibility of the package. Default is [Public].
+	Visibility Visibility
+	// Description, Homepage, License SPDX identifier, Owner team and Tags describe the package
+	// to people visiting its page. They are optional and not used by the go tool.
+	Description string
+	Homepage    string
+	License     string
+	Owner       string
+	Tags        []string
+	// PublishAt and ExpireAt limit time window when package is resolved. Unlimited if zero.
+	PublishAt time.Time
+	ExpireAt  time.Time
+	// Headers added to package responses. They are set last, so Cache-Control and Content-Type can be replaced too.
+	Headers map[string]string
+}
+
+// Active reports whether package is published and not expired at a given time.
+func (pkg Package) Active(now time.Time) bool {
+	return (pkg.PublishAt.IsZero() || !now.Before(pkg.PublishAt)) && (pkg.ExpireAt.IsZero() || now.Before(pkg.ExpireAt))
+}
+
+// CanonicalPath returns [Package.Canonical] or [Package.Path] if it is not set.
+func (pkg Package) CanonicalPath() string {
+	return cmp.Or(pkg.Canonical, pkg.Path)
 }
 
 // RenderHead 'go-import' and 'go-source' HTML meta elements of the package
 func (pkg Package) RenderHead(wr io.Writer, host string) error {
-	return pkgTmpl.ExecuteTemplate(wr, pkgHeadName, struct {
-		Package Package
-		Host    string
-	}{
-		Package: pkg,
-		Host:    host,
-	})
+	return defaultTemplates.RenderHead(wr, pkg, host)
 }
 
 // RenderDocument full HTML document of the package.
 func (pkg Package) RenderDocument(wr io.Writer, host, subpath string) error {
-	return pkgTmpl.ExecuteTemplate(wr, pkgDocName, struct {
-		Package Package
-		Host    string
-		Subpath string
-	}{
-		Package: pkg,
-		Host:    host,
-		Subpath: subpath,
-	})
+	return defaultTemplates.RenderDocument(wr, pkg, host, subpath)
+}
+
+// RenderIndex full HTML document listing a given packages.
+func RenderIndex(wr io.Writer, host string, pset []Package) error {
+	return defaultTemplates.RenderIndex(wr, host, pset)
 }
 
 // AdjustFields to cleanup existing fields and detect missing vcs and display.
 // Returns [ErrInvalidPackage] if package is configured incorrectly
 func (pkg Package) AdjustFields() (Package, error) {
-	// Cleanup path field
-	pkg.Path = strings.TrimSpace(pkg.Path)
-	pkg.Path = strings.TrimSuffix(pkg.Path, "/")
+	// Cleanup path fields
+	pkg.Path = cleanPath(pkg.Path)
+
+	if pkg.MovedTo != "" {
+		pkg.MovedTo = cleanPath(pkg.MovedTo)
+
+		if pkg.MovedTo == pkg.Path {
+			return Package{}, fmt.Errorf("%w: package moved to its own path: %s", ErrInvalidPackage, pkg.Path)
+		}
+	}
+
+	if len(pkg.Aliases) > 0 {
+		aliases := make([]string, len(pkg.Aliases))
+
+		for i, alias := range pkg.Aliases {
+			aliases[i] = cleanPath(alias)
+
+			if slices.Contains(aliases[:i], aliases[i]) || aliases[i] == pkg.Path {
+				return Package{}, fmt.Errorf("%w: duplicate alias: %s", ErrInvalidPackage, aliases[i])
+			}
+		}
+
+		pkg.Aliases = aliases
+	}
+
+	if pkg.Canonical != "" {
+		pkg.Canonical = cleanPath(pkg.Canonical)
 
-	if !strings.HasPrefix(pkg.Path, "/") {
-		pkg.Path = "/" + pkg.Path
+		if pkg.Canonical != pkg.Path && !slices.Contains(pkg.Aliases, pkg.Canonical) {
+			return Package{}, fmt.Errorf("%w: canonical path is not an alias: %s", ErrInvalidPackage, pkg.Canonical)
+		}
+	}
+
+	if _, ok := visibilityMapStrValues[pkg.Visibility]; !ok {
+		return Package{}, fmt.Errorf("%w: %w: %d", ErrInvalidPackage, ErrInvalidVisibility, pkg.Visibility)
+	}
+
+	if !pkg.PublishAt.IsZero() && !pkg.ExpireAt.IsZero() && !pkg.ExpireAt.After(pkg.PublishAt) {
+		return Package{}, fmt.Errorf("%w: package expires before it is published: %s", ErrInvalidPackage, pkg.Path)
+	}
+
+	if pkg.CacheAge < 0 {
+		return Package{}, fmt.Errorf("%w: negative cache age: %s", ErrInvalidPackage, pkg.CacheAge)
+	}
+
+	for name, value := range pkg.Headers {
+		if name == "" || strings.ContainsAny(name, " :\t\r\n") || strings.ContainsAny(value, "\r\n") {
+			return Package{}, fmt.Errorf("%w: invalid header: %q", ErrInvalidPackage, name)
+		}
+	}
+
+	if pkg.Homepage != "" {
+		homepage, err := url.Parse(strings.TrimSpace(pkg.Homepage))
+		if err != nil || !slices.Contains([]string{"https", "http"}, homepage.Scheme) {
+			return Package{}, fmt.Errorf("%w: invalid homepage: %s", ErrInvalidPackage, pkg.Homepage)
+		}
+
+		pkg.Homepage = homepage.String()
 	}
 
 	// Cleanup repository url field
@@ -98,3 +175,35 @@ func (pkg Package) AdjustFields() (Package, error) {
 
 	return pkg, nil
 }
+
+// withAliases returns package for its own path and for each of aliases.
+// All of them keep the same canonical path.
+func (pkg Package) withAliases() []Package {
+	paths := append([]string{pkg.Path}, pkg.Aliases...)
+	pset := make([]Package, len(paths))
+
+	for i, path := range paths {
+		pset[i] = pkg
+		pset[i].Path = path
+		pset[i].Aliases = nil
+
+		if len(paths) > 1 {
+			pset[i].Aliases = slices.Delete(slices.Clone(paths), i, i+1)
+			pset[i].Canonical = pkg.CanonicalPath()
+		}
+	}
+
+	return pset
+}
+
+// cleanPath trims spaces and trailing slash, and adds leading slash.
+func cleanPath(path string) string {
+	path = strings.TrimSpace(path)
+	path = strings.TrimSuffix(path, "/")
+
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	return path
+}
@@ -4,8 +4,10 @@ import (
 	"bytes"
 	"io"
 	"os"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"go.wamod.dev/vanityurl"
 )
@@ -115,6 +117,33 @@ func TestPackageRenderDocument(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name:   "metadata",
+			writer: bytes.NewBuffer(nil),
+			host:   "go.example.com",
+			pkg: vanityurl.Package{
+				Path:          "/foo",
+				VCS:           vanityurl.Git,
+				Display:       "display",
+				RepositoryURL: "https://git.repo.com",
+				Description:   "Foo <utilities>",
+				Homepage:      "https://foo.example.com",
+				License:       "MIT",
+				Owner:         "platform",
+				Tags:          []string{"http", "cli"},
+			},
+			wantElement: []string{
+				`<meta name="description" content="Foo &lt;utilities&gt;">`,
+				`<meta property="og:title" content="go.example.com/foo">`,
+				`<meta property="og:description" content="Foo &lt;utilities&gt;">`,
+				`<meta property="og:url" content="https://go.example.com/foo">`,
+				`<p>Foo &lt;utilities&gt;</p>`,
+				`<p>Homepage: <a href="https://foo.example.com">https://foo.example.com</a></p>`,
+				`<p>License: MIT</p>`,
+				`<p>Owner: platform</p>`,
+				`<p>Tags: http, cli</p>`,
+			},
+		},
 		{
 			name:    "bad_writer",
 			writer:  failWriter{os.ErrClosed},
@@ -206,6 +235,33 @@ func TestPackageAdjustFields(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "clean_moved_to",
+			pkg: vanityurl.Package{
+				Path:          "/oldlib",
+				VCS:           vanityurl.Git,
+				Display:       "display",
+				RepositoryURL: "https://github.com/foo",
+				MovedTo:       " newlib/ ",
+			},
+			want: vanityurl.Package{
+				Path:          "/oldlib",
+				VCS:           vanityurl.Git,
+				Display:       "display",
+				RepositoryURL: "https://github.com/foo",
+				MovedTo:       "/newlib",
+			},
+			wantErr: false,
+		},
+		{
+			name: "moved_to_self",
+			pkg: vanityurl.Package{
+				Path:          "/oldlib",
+				RepositoryURL: "https://github.com/foo",
+				MovedTo:       "/oldlib/",
+			},
+			wantErr: true,
+		},
 		{
 			name: "add_path_prefix",
 			pkg: vanityurl.Package{
@@ -238,6 +294,95 @@ func TestPackageAdjustFields(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "clean_aliases",
+			pkg: vanityurl.Package{
+				Path:          "/util",
+				VCS:           vanityurl.Git,
+				Display:       "util_display",
+				RepositoryURL: "https://git.repo.com/util",
+				Aliases:       []string{"x/util/"},
+				Canonical:     "/x/util/",
+			},
+			want: vanityurl.Package{
+				Path:          "/util",
+				VCS:           vanityurl.Git,
+				Display:       "util_display",
+				RepositoryURL: "https://git.repo.com/util",
+				Aliases:       []string{"/x/util"},
+				Canonical:     "/x/util",
+			},
+			wantErr: false,
+		},
+		{
+			name: "alias_of_own_path",
+			pkg: vanityurl.Package{
+				Path:          "/util",
+				VCS:           vanityurl.Git,
+				Display:       "util_display",
+				RepositoryURL: "https://git.repo.com/util",
+				Aliases:       []string{"/util/"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "canonical_not_alias",
+			pkg: vanityurl.Package{
+				Path:          "/util",
+				VCS:           vanityurl.Git,
+				Display:       "util_display",
+				RepositoryURL: "https://git.repo.com/util",
+				Aliases:       []string{"/x/util"},
+				Canonical:     "/y/util",
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative_cache_age",
+			pkg: vanityurl.Package{
+				Path:          "/foo",
+				RepositoryURL: "https://github.com/example/foo",
+				CacheAge:      -time.Second,
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid_header",
+			pkg: vanityurl.Package{
+				Path:          "/foo",
+				RepositoryURL: "https://github.com/example/foo",
+				Headers:       map[string]string{"X-Foo": "bar\r\nSet-Cookie: x"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid_homepage",
+			pkg: vanityurl.Package{
+				Path:          "/foo",
+				RepositoryURL: "https://github.com/example/foo",
+				Homepage:      "javascript:alert(1)",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid_visibility",
+			pkg: vanityurl.Package{
+				Path:          "/foo",
+				RepositoryURL: "https://github.com/example/foo",
+				Visibility:    vanityurl.Visibility(42),
+			},
+			wantErr: true,
+		},
+		{
+			name: "expires_before_published",
+			pkg: vanityurl.Package{
+				Path:          "/foo",
+				RepositoryURL: "https://github.com/example/foo",
+				PublishAt:     time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+				ExpireAt:      time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			},
+			wantErr: true,
+		},
 		{
 			name: "fail_repo_url_parse",
 			pkg: vanityurl.Package{
@@ -287,9 +432,40 @@ func TestPackageAdjustFields(t *testing.T) {
 				t.Errorf("Package.AdjustFields() error = %v; wantErr = %v", err, tc.wantErr)
 			}
 
-			if got != tc.want {
+			if !reflect.DeepEqual(got, tc.want) {
 				t.Errorf("Package.AdjustFields() = %v; want = %v", got, tc.want)
 			}
 		})
 	}
 }
+
+func TestRenderIndex(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+
+	err := vanityurl.RenderIndex(buf, "go.example.com", []vanityurl.Package{
+		{Path: "/bar"},
+		{Path: "/foo", Description: "Foo utilities", Tags: []string{"http", "cli"}},
+	})
+	if err != nil {
+		t.Fatalf("RenderIndex() = %v", err)
+	}
+
+	wantElement := []string{
+		`<!DOCTYPE html>`,
+		`<title>go.example.com</title>`,
+		`<li><a href="https://pkg.go.dev/go.example.com/bar">go.example.com/bar</a></li>`,
+		`<li><a href="https://pkg.go.dev/go.example.com/foo">go.example.com/foo</a> - Foo utilities [http, cli]</li>`,
+		`</html>`,
+	}
+
+	output := buf.String()
+	for _, element := range wantElement {
+		if !strings.Contains(output, element) {
+			t.Errorf("RenderIndex() = %s; expected element in output = %s", output, element)
+		}
+	}
+
+	if err := vanityurl.RenderIndex(failWriter{os.ErrClosed}, "go.example.com", nil); err == nil {
+		t.Errorf("RenderIndex() = nil; want error for failing writer")
+	}
+}
@@ -0,0 +1,209 @@
+package vanityurl
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRateLimitIdleTimeout = 10 * time.Minute
+	defaultRateLimitMaxClients  = 100_000
+	// rateLimitIPv6Bits of IPv6 client address sharing a bucket, as clients usually get a whole /64.
+	rateLimitIPv6Bits = 64
+)
+
+// RateLimit of a token bucket.
+type RateLimit struct {
+	// Rate of requests per second refilling the bucket. Unlimited if zero.
+	Rate float64
+	// Burst of requests allowed at once, i.e. bucket size. Default is Rate rounded up, at least 1.
+	Burst int
+}
+
+func (l RateLimit) burst() float64 {
+	if l.Burst > 0 {
+		return float64(l.Burst)
+	}
+
+	return max(math.Ceil(l.Rate), 1)
+}
+
+// RateLimitOptions for [NewRateLimitHandler].
+type RateLimitOptions struct {
+	// GoGet limit of requests made by the go tool per client.
+	GoGet RateLimit
+	// Browser limit of other requests per client.
+	Browser RateLimit
+	// TrustedProxies allowed to set client IP with Forwarded or X-Forwarded-For header.
+	TrustedProxies []netip.Prefix
+	// Identify returns client identity used as a key instead of IP, e.g. authenticated user.
+	// It is called only if client IP is not limited, so throttled requests don't pay for
+	// credential checks. IP is used if nil or if it returns empty string.
+	Identify func(r *http.Request) string
+	// ExemptPaths are not limited, e.g. health endpoints.
+	ExemptPaths []string
+	// IdleTimeout after which buckets of inactive clients are evicted. Default is 10m.
+	IdleTimeout time.Duration
+	// MaxClients is the number of buckets kept at once. When reached, an arbitrary bucket is
+	// evicted for a new client. Default is 100000.
+	MaxClients int
+	// Now returns current time. Default is [time.Now].
+	Now func() time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type rateLimiter struct {
+	opts RateLimitOptions
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// NewRateLimitHandler wraps handler with token bucket rate limiter keyed by client identity or IP,
+// with separate limits for go-get and browser requests. IPv6 clients are keyed by their /64
+// network. Limited requests are answered with 429 Too Many Requests and Retry-After header.
+// Buckets of idle clients are evicted, and memory is bounded by [RateLimitOptions.MaxClients].
+func NewRateLimitHandler(next http.Handler, opts *RateLimitOptions) http.Handler {
+	if opts == nil {
+		opts = &RateLimitOptions{}
+	}
+
+	limiter := &rateLimiter{
+		opts:    *opts,
+		buckets: map[string]*bucket{},
+	}
+
+	limiter.opts.IdleTimeout = cmp.Or(max(opts.IdleTimeout, 0), defaultRateLimitIdleTimeout)
+	limiter.opts.MaxClients = cmp.Or(max(opts.MaxClients, 0), defaultRateLimitMaxClients)
+
+	if limiter.opts.Now == nil {
+		limiter.opts.Now = time.Now
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if wait, ok := limiter.allow(r); !ok {
+			w.Header().Set("Retry-After", strconv.FormatInt(int64(math.Ceil(wait.Seconds())), 10))
+			http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allow takes a token from client bucket. Returns time to wait for the next token if bucket is empty.
+func (l *rateLimiter) allow(r *http.Request) (time.Duration, bool) {
+	limit := l.opts.Browser
+	if isGoGet(r) {
+		limit = l.opts.GoGet
+	}
+
+	if limit.Rate <= 0 || slices.Contains(l.opts.ExemptPaths, r.URL.Path) {
+		return 0, true
+	}
+
+	ipKey := fmt.Sprintf("%s|%s", clientType(r), ipClient(clientIP(r, l.opts.TrustedProxies)))
+
+	// Client IP is checked first, so credentials of throttled requests are never verified
+	if wait, ok := l.take(ipKey, limit, false); !ok {
+		return wait, false
+	}
+
+	if l.opts.Identify != nil {
+		if client := l.opts.Identify(r); client != "" {
+			return l.take(fmt.Sprintf("%s|%s", clientType(r), client), limit, true)
+		}
+	}
+
+	return l.take(ipKey, limit, true)
+}
+
+// take refills bucket of key and takes a token from it if consume is set, or only checks
+// that one is available otherwise. Returns time to wait for the next token if bucket is empty.
+func (l *rateLimiter) take(key string, limit RateLimit, consume bool) (time.Duration, bool) {
+	now := l.opts.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		// Missing bucket is full
+		if !consume {
+			return 0, true
+		}
+
+		l.makeRoom()
+
+		b = &bucket{tokens: limit.burst(), last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = min(b.tokens+now.Sub(b.last).Seconds()*limit.Rate, limit.burst())
+	b.last = now
+
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second)), false
+	}
+
+	if consume {
+		b.tokens--
+	}
+
+	return 0, true
+}
+
+// sweep evicts idle buckets, at most once per idle timeout.
+// Evicted client starts with a full bucket on the next request.
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.opts.IdleTimeout {
+		return
+	}
+
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= l.opts.IdleTimeout {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// makeRoom evicts arbitrary buckets until there is room for a new one.
+func (l *rateLimiter) makeRoom() {
+	for key := range l.buckets {
+		if len(l.buckets) < l.opts.MaxClients {
+			return
+		}
+
+		delete(l.buckets, key)
+	}
+}
+
+// ipClient returns rate limit key of client address. IPv6 addresses are masked to /64,
+// so rotating addresses within a network does not create new buckets.
+func ipClient(addr netip.Addr) string {
+	if addr.Is6() {
+		prefix, err := addr.Prefix(rateLimitIPv6Bits)
+		if err == nil {
+			return prefix.String()
+		}
+	}
+
+	return addr.String()
+}
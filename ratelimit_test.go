@@ -0,0 +1,136 @@
+package vanityurl_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.wamod.dev/vanityurl"
+)
+
+func TestNewRateLimitHandler(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	identified := 0
+
+	handler := vanityurl.NewRateLimitHandler(
+		http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) }),
+		&vanityurl.RateLimitOptions{
+			GoGet:   vanityurl.RateLimit{Rate: 1, Burst: 2},
+			Browser: vanityurl.RateLimit{Rate: 0.5},
+			Identify: func(r *http.Request) string {
+				identified++
+
+				return r.Header.Get("X-User")
+			},
+			ExemptPaths: []string{"/healthz"},
+			Now:         func() time.Time { return now },
+		},
+	)
+
+	type request struct {
+		target         string
+		remoteAddr     string
+		user           string
+		advance        time.Duration
+		wantStatus     int
+		wantRetryAfter string
+		wantIdentified int
+	}
+
+	steps := []request{
+		{target: "/foo?go-get=1", remoteAddr: "192.0.2.1:1", wantStatus: http.StatusOK, wantIdentified: 1},
+		{target: "/foo?go-get=1", remoteAddr: "192.0.2.1:2", wantStatus: http.StatusOK, wantIdentified: 2},
+		{target: "/foo?go-get=1", remoteAddr: "192.0.2.1:3", wantStatus: http.StatusTooManyRequests, wantRetryAfter: "1", wantIdentified: 2},
+		// Browser and go-get requests are limited separately
+		{target: "/foo", remoteAddr: "192.0.2.1:4", wantStatus: http.StatusOK, wantIdentified: 3},
+		{target: "/foo", remoteAddr: "192.0.2.1:5", wantStatus: http.StatusTooManyRequests, wantRetryAfter: "2", wantIdentified: 3},
+		// Other clients are not affected
+		{target: "/foo?go-get=1", remoteAddr: "192.0.2.2:1", wantStatus: http.StatusOK, wantIdentified: 4},
+		// Identity is not checked for throttled IP
+		{target: "/foo?go-get=1", remoteAddr: "192.0.2.1:6", user: "alice", wantStatus: http.StatusTooManyRequests, wantRetryAfter: "1", wantIdentified: 4},
+		// Identified client has its own bucket, not taking tokens of IP
+		{target: "/foo?go-get=1", remoteAddr: "192.0.2.3:1", user: "alice", wantStatus: http.StatusOK, wantIdentified: 5},
+		{target: "/foo?go-get=1", remoteAddr: "192.0.2.3:2", user: "alice", wantStatus: http.StatusOK, wantIdentified: 6},
+		{target: "/foo?go-get=1", remoteAddr: "192.0.2.4:1", user: "alice", wantStatus: http.StatusTooManyRequests, wantRetryAfter: "1", wantIdentified: 7},
+		{target: "/foo?go-get=1", remoteAddr: "192.0.2.3:3", wantStatus: http.StatusOK, wantIdentified: 8},
+		{target: "/healthz", remoteAddr: "192.0.2.1:7", wantStatus: http.StatusOK, wantIdentified: 8},
+		// IPv6 clients share bucket of /64 network
+		{target: "/foo", remoteAddr: "[2001:db8::1]:1", wantStatus: http.StatusOK, wantIdentified: 9},
+		{target: "/foo", remoteAddr: "[2001:db8::2]:1", wantStatus: http.StatusTooManyRequests, wantRetryAfter: "2", wantIdentified: 9},
+		{target: "/foo", remoteAddr: "[2001:db8:0:1::1]:1", wantStatus: http.StatusOK, wantIdentified: 10},
+		// Bucket is refilled over time
+		{target: "/foo?go-get=1", remoteAddr: "192.0.2.1:8", advance: time.Second, wantStatus: http.StatusOK, wantIdentified: 11},
+		{target: "/foo?go-get=1", remoteAddr: "192.0.2.1:9", wantStatus: http.StatusTooManyRequests, wantRetryAfter: "1", wantIdentified: 11},
+		// Idle bucket is evicted and starts full
+		{target: "/foo?go-get=1", remoteAddr: "192.0.2.1:10", advance: time.Hour, wantStatus: http.StatusOK, wantIdentified: 12},
+		{target: "/foo?go-get=1", remoteAddr: "192.0.2.1:11", wantStatus: http.StatusOK, wantIdentified: 13},
+	}
+
+	for i, step := range steps {
+		now = now.Add(step.advance)
+
+		r := httptest.NewRequest(http.MethodGet, step.target, nil)
+		r.RemoteAddr = step.remoteAddr
+
+		if step.user != "" {
+			r.Header.Set("X-User", step.user)
+		}
+
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, r)
+
+		if w.Code != step.wantStatus {
+			t.Errorf("step %d: status = %d; want = %d", i, w.Code, step.wantStatus)
+		}
+
+		if got := w.Header().Get("Retry-After"); got != step.wantRetryAfter {
+			t.Errorf("step %d: Retry-After = %q; want = %q", i, got, step.wantRetryAfter)
+		}
+
+		if identified != step.wantIdentified {
+			t.Errorf("step %d: Identify() calls = %d; want = %d", i, identified, step.wantIdentified)
+		}
+	}
+}
+
+func TestNewRateLimitHandlerMaxClients(t *testing.T) {
+	handler := vanityurl.NewRateLimitHandler(
+		http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) }),
+		&vanityurl.RateLimitOptions{
+			GoGet:      vanityurl.RateLimit{Rate: 0.001},
+			MaxClients: 2,
+		},
+	)
+
+	serve := func(remoteAddr string) int {
+		r := httptest.NewRequest(http.MethodGet, "/foo?go-get=1", nil)
+		r.RemoteAddr = remoteAddr
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		return w.Code
+	}
+
+	// Each new client evicts another bucket once limit is reached, so exhausted clients are
+	// eventually let through again
+	for _, addr := range []string{"192.0.2.1:1", "192.0.2.2:1", "192.0.2.3:1", "192.0.2.4:1"} {
+		if code := serve(addr); code != http.StatusOK {
+			t.Errorf("%s: status = %d; want = %d", addr, code, http.StatusOK)
+		}
+	}
+
+	evicted := 0
+
+	for _, addr := range []string{"192.0.2.1:2", "192.0.2.2:2"} {
+		if serve(addr) == http.StatusOK {
+			evicted++
+		}
+	}
+
+	if evicted == 0 {
+		t.Errorf("evicted buckets = 0; want buckets of first clients evicted")
+	}
+}
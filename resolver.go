@@ -7,54 +7,123 @@ import (
 	"slices"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 )
 
 // Resolver type for packages.
 type Resolver interface {
 	// ResolvePackage for a given path.
-	// Returns [ErrPackageNotFound] if package not found.
+	// Returns [ErrPackageNotFound] if package not found, or retired package with [ErrPackageGone].
 	ResolvePackage(ctx context.Context, path string) (Package, error)
 }
 
+// Lister can be implemented by [Resolver] to enumerate its packages.
+type Lister interface {
+	// ListPackages returns all packages sorted by path.
+	ListPackages(ctx context.Context) ([]Package, error)
+}
+
 type resolver struct {
+	all []Package
+	now func() time.Time
+
+	mu   sync.RWMutex
 	pset []Package
+	at   time.Time
+	next time.Time
 }
 
 // NewResolver creates a static resolver with a given [Package] set.
+// Package aliases are resolved as separate packages, and all paths must be unique.
 func NewResolver(pset ...Package) (Resolver, error) {
+	return NewResolverWithClock(time.Now, pset...)
+}
+
+// NewResolverWithClock creates a static resolver like [NewResolver], using now as current time
+// for [Package.PublishAt] and [Package.ExpireAt]. Packages outside of their time window are not found
+// and not listed, and they appear or disappear at the given instants without restart.
+func NewResolverWithClock(now func() time.Time, pset ...Package) (Resolver, error) {
+	var list []Package
+
 	pathMap := map[string]struct{}{}
-	for i, pkg := range pset {
-		if _, ok := pathMap[pkg.Path]; ok {
-			return nil, fmt.Errorf("%w: duplicate paths: %s", ErrInvalidPackage, pkg.Path)
-		}
 
+	for _, pkg := range pset {
 		pkg, err := pkg.AdjustFields()
 		if err != nil {
 			return nil, err
 		}
 
-		pathMap[pkg.Path] = struct{}{}
-		pset[i] = pkg
+		for _, alias := range pkg.withAliases() {
+			if _, ok := pathMap[alias.Path]; ok {
+				return nil, fmt.Errorf("%w: duplicate paths: %s", ErrInvalidPackage, alias.Path)
+			}
+
+			pathMap[alias.Path] = struct{}{}
+			list = append(list, alias)
+		}
 	}
 
-	slices.SortFunc(pset, func(a, b Package) int {
+	slices.SortFunc(list, func(a, b Package) int {
 		return strings.Compare(a.Path, b.Path)
 	})
 
-	return &resolver{pset}, nil
+	r := &resolver{all: list, now: now}
+	r.refresh(now())
+
+	return r, nil
+}
+
+// view returns packages active now, refreshed once current time passes the next publish or expiry instant.
+func (r *resolver) view() []Package {
+	now := r.now()
+
+	r.mu.RLock()
+	pset, at, next := r.pset, r.at, r.next
+	r.mu.RUnlock()
+
+	if !now.Before(at) && (next.IsZero() || now.Before(next)) {
+		return pset
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.refresh(now)
+
+	return r.pset
+}
+
+// refresh active packages and the next instant when they change. Must be called with mu locked.
+func (r *resolver) refresh(now time.Time) {
+	r.pset, r.at, r.next = nil, now, time.Time{}
+
+	for _, pkg := range r.all {
+		if pkg.Active(now) {
+			r.pset = append(r.pset, pkg)
+		}
+
+		for _, instant := range []time.Time{pkg.PublishAt, pkg.ExpireAt} {
+			if instant.After(now) && (r.next.IsZero() || instant.Before(r.next)) {
+				r.next = instant
+			}
+		}
+	}
 }
 
 func (r *resolver) ResolvePackage(_ context.Context, path string) (Package, error) {
-	i := sort.Search(len(r.pset), func(i int) bool {
-		return r.pset[i].Path >= path
+	pset := r.view()
+
+	i := sort.Search(len(pset), func(i int) bool {
+		return pset[i].Path >= path
 	})
 
-	if i < len(r.pset) && r.pset[i].Path == path {
-		return r.pset[i], nil
+	if i < len(pset) && pset[i].Path == path {
+		return resolved(pset[i])
 	}
 
-	if i > 0 && strings.HasPrefix(path, r.pset[i-1].Path+"/") {
-		return r.pset[i-1], nil
+	if i > 0 && strings.HasPrefix(path, pset[i-1].Path+"/") {
+		return resolved(pset[i-1])
 	}
 
 	var match *Package
@@ -62,25 +131,38 @@ func (r *resolver) ResolvePackage(_ context.Context, path string) (Package, erro
 	matchSubpathLen := len(path)
 
 	for j := 0; j < i; j++ {
-		if len(r.pset[j].Path) >= len(path) {
+		if len(pset[j].Path) >= len(path) {
 			continue
 		}
 
-		subpath := strings.TrimPrefix(path, r.pset[j].Path+"/")
+		subpath := strings.TrimPrefix(path, pset[j].Path+"/")
 
 		if len(subpath) < matchSubpathLen {
 			matchSubpathLen = len(subpath)
-			match = &r.pset[j]
+			match = &pset[j]
 		}
 	}
 
 	if match != nil {
-		return *match, nil
+		return resolved(*match)
 	}
 
 	return Package{}, ErrPackageNotFound
 }
 
+// resolved returns package with [ErrPackageGone] if it is retired.
+func resolved(pkg Package) (Package, error) {
+	if pkg.Retired {
+		return pkg, ErrPackageGone
+	}
+
+	return pkg, nil
+}
+
+func (r *resolver) ListPackages(_ context.Context) ([]Package, error) {
+	return slices.Clone(r.view()), nil
+}
+
 type multiResolver struct {
 	rset []Resolver
 }
@@ -95,6 +177,9 @@ func (r *multiResolver) ResolvePackage(ctx context.Context, path string) (Packag
 		pkg, err := rr.ResolvePackage(ctx, path)
 		if errors.Is(err, ErrPackageNotFound) {
 			continue
+		} else if errors.Is(err, ErrPackageGone) {
+			// Retired package is not looked up in other resolvers
+			return pkg, err
 		} else if err != nil {
 			return Package{}, err
 		}
@@ -104,3 +189,38 @@ func (r *multiResolver) ResolvePackage(ctx context.Context, path string) (Packag
 
 	return Package{}, ErrPackageNotFound
 }
+
+// ListPackages of all resolvers implementing [Lister].
+// If a path is listed by several resolvers then the first one wins, same as when resolving.
+func (r *multiResolver) ListPackages(ctx context.Context) ([]Package, error) {
+	var pset []Package
+
+	seen := map[string]struct{}{}
+
+	for _, rr := range r.rset {
+		lister, ok := rr.(Lister)
+		if !ok {
+			continue
+		}
+
+		list, err := lister.ListPackages(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, pkg := range list {
+			if _, ok := seen[pkg.Path]; ok {
+				continue
+			}
+
+			seen[pkg.Path] = struct{}{}
+			pset = append(pset, pkg)
+		}
+	}
+
+	slices.SortFunc(pset, func(a, b Package) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+
+	return pset, nil
+}
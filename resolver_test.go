@@ -2,8 +2,11 @@ package vanityurl_test
 
 import (
 	"context"
+	"errors"
 	"os"
+	"reflect"
 	"testing"
+	"time"
 
 	"go.wamod.dev/vanityurl"
 )
@@ -114,6 +117,68 @@ func TestNewResolver(t *testing.T) {
 				RepositoryURL: "https://git.example.com/foo",
 			},
 		},
+		{
+			name: "alias",
+			pset: []vanityurl.Package{
+				{
+					Path:          "/util",
+					VCS:           vanityurl.Git,
+					Display:       "util_display",
+					RepositoryURL: "https://git.example.com/util",
+					Aliases:       []string{"/x/util"},
+					Canonical:     "/x/util",
+				},
+			},
+			resolvePath: "/x/util/sub",
+			wantResolvePkg: vanityurl.Package{
+				Path:          "/x/util",
+				VCS:           vanityurl.Git,
+				Display:       "util_display",
+				RepositoryURL: "https://git.example.com/util",
+				Aliases:       []string{"/util"},
+				Canonical:     "/x/util",
+			},
+		},
+		{
+			name: "alias_conflict",
+			pset: []vanityurl.Package{
+				{
+					Path:          "/util",
+					VCS:           vanityurl.Git,
+					RepositoryURL: "https://git.example.com/util",
+					Aliases:       []string{"/x/util"},
+				},
+				{
+					Path:          "/x/util",
+					VCS:           vanityurl.Git,
+					RepositoryURL: "https://git.example.com/xutil",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "retired",
+			pset: []vanityurl.Package{
+				{
+					Path:          "/foo",
+					VCS:           vanityurl.Git,
+					Display:       "foo_display",
+					RepositoryURL: "https://git.example.com/foo",
+					Retired:       true,
+					RetiredReason: "Removed",
+				},
+			},
+			resolvePath:    "/foo/bar",
+			wantResolveErr: true,
+			wantResolvePkg: vanityurl.Package{
+				Path:          "/foo",
+				VCS:           vanityurl.Git,
+				Display:       "foo_display",
+				RepositoryURL: "https://git.example.com/foo",
+				Retired:       true,
+				RetiredReason: "Removed",
+			},
+		},
 		{
 			name: "duplicate",
 			pset: []vanityurl.Package{
@@ -160,7 +225,7 @@ func TestNewResolver(t *testing.T) {
 				t.Errorf("Resolver.ResolvePackage() = %v; wantResolveErr = %v", err, tc.wantResolveErr)
 			}
 
-			if pkg != tc.wantResolvePkg {
+			if !reflect.DeepEqual(pkg, tc.wantResolvePkg) {
 				t.Errorf("Resolver.ResolvePackage() = %v; wantResolvePkg = %v", pkg, tc.wantResolvePkg)
 			}
 		})
@@ -184,13 +249,18 @@ func (resolver failingResolver) ResolvePackage(_ context.Context, _ string) (van
 	return vanityurl.Package{}, resolver.err
 }
 
+func (resolver failingResolver) CheckHealth(_ context.Context) error {
+	return resolver.err
+}
+
 func TestNewMultiResolver(t *testing.T) {
 	tt := []struct {
-		name    string
-		rset    []vanityurl.Resolver
-		path    string
-		want    vanityurl.Package
-		wantErr bool
+		name     string
+		rset     []vanityurl.Resolver
+		path     string
+		want     vanityurl.Package
+		wantErr  bool
+		wantGone bool
 	}{
 		{
 			name:    "empty",
@@ -240,6 +310,37 @@ func TestNewMultiResolver(t *testing.T) {
 				RepositoryURL: "https://git.example.com/foo",
 			},
 		},
+		{
+			name: "gone_stops_search",
+			rset: []vanityurl.Resolver{
+				mustResolver(t,
+					vanityurl.Package{
+						Path:          "/foo",
+						VCS:           vanityurl.Git,
+						Display:       "foo_display",
+						RepositoryURL: "https://git.example.com/foo",
+						Retired:       true,
+					},
+				),
+				mustResolver(t,
+					vanityurl.Package{
+						Path:          "/foo",
+						VCS:           vanityurl.Git,
+						Display:       "other_display",
+						RepositoryURL: "https://git.example.com/other",
+					},
+				),
+			},
+			path: "/foo/bar",
+			want: vanityurl.Package{
+				Path:          "/foo",
+				VCS:           vanityurl.Git,
+				Display:       "foo_display",
+				RepositoryURL: "https://git.example.com/foo",
+				Retired:       true,
+			},
+			wantGone: true,
+		},
 		{
 			name: "fail_before_second",
 			rset: []vanityurl.Resolver{
@@ -263,13 +364,137 @@ func TestNewMultiResolver(t *testing.T) {
 			resolver := vanityurl.NewMultiResolver(tc.rset...)
 
 			pkg, err := resolver.ResolvePackage(context.Background(), tc.path)
-			if tc.wantErr != (err != nil) {
+			if tc.wantGone {
+				if !errors.Is(err, vanityurl.ErrPackageGone) {
+					t.Errorf("MultiResolver.ResolvePackage() = %v; want %v", err, vanityurl.ErrPackageGone)
+				}
+			} else if tc.wantErr != (err != nil) {
 				t.Errorf("MultiResolver.ResolvePackage() = %v; wantErr = %v", err, tc.wantErr)
 			}
 
-			if tc.want != pkg {
+			if !reflect.DeepEqual(pkg, tc.want) {
 				t.Errorf("MultiResolver.ResolvePackage() = %v; want = %v", pkg, tc.want)
 			}
 		})
 	}
 }
+
+func TestListPackages(t *testing.T) {
+	foo := vanityurl.Package{
+		Path:          "/foo",
+		VCS:           vanityurl.Git,
+		Display:       "foo_display",
+		RepositoryURL: "https://git.example.com/foo",
+	}
+	bar := vanityurl.Package{
+		Path:          "/bar",
+		VCS:           vanityurl.Git,
+		Display:       "bar_display",
+		RepositoryURL: "https://git.example.com/bar",
+	}
+	otherFoo := vanityurl.Package{
+		Path:          "/foo",
+		VCS:           vanityurl.Git,
+		Display:       "other_display",
+		RepositoryURL: "https://git.example.com/other",
+	}
+
+	tt := []struct {
+		name     string
+		resolver vanityurl.Resolver
+		want     []vanityurl.Package
+		wantErr  bool
+	}{
+		{
+			name:     "static",
+			resolver: mustResolver(t, foo, bar),
+			want:     []vanityurl.Package{bar, foo},
+		},
+		{
+			name: "multi",
+			resolver: vanityurl.NewMultiResolver(
+				mustResolver(t, foo),
+				failingResolver{},
+				mustResolver(t, bar, otherFoo),
+			),
+			want: []vanityurl.Package{bar, foo},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			lister, ok := tc.resolver.(vanityurl.Lister)
+			if !ok {
+				t.Fatalf("Resolver does not implement Lister")
+			}
+
+			got, err := lister.ListPackages(context.Background())
+			if tc.wantErr != (err != nil) {
+				t.Errorf("Lister.ListPackages() = %v; wantErr = %v", err, tc.wantErr)
+			}
+
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Lister.ListPackages() = %v; want = %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestNewResolverWithClock(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	resolver, err := vanityurl.NewResolverWithClock(func() time.Time { return now },
+		vanityurl.Package{Path: "/foo", VCS: vanityurl.Git, RepositoryURL: "https://git.example.com/foo"},
+		vanityurl.Package{
+			Path:          "/foo/next",
+			VCS:           vanityurl.Git,
+			RepositoryURL: "https://git.example.com/next",
+			PublishAt:     now.Add(time.Hour),
+		},
+		vanityurl.Package{
+			Path:          "/old",
+			VCS:           vanityurl.Git,
+			RepositoryURL: "https://git.example.com/old",
+			ExpireAt:      now.Add(2 * time.Hour),
+		},
+	)
+	if err != nil {
+		t.Fatalf("NewResolverWithClock() = %v", err)
+	}
+
+	steps := []struct {
+		advance  time.Duration
+		path     string
+		wantPath string
+	}{
+		{path: "/foo/next/sub", wantPath: "/foo"},
+		{path: "/old", wantPath: "/old"},
+		{advance: time.Hour, path: "/foo/next/sub", wantPath: "/foo/next"},
+		{advance: time.Hour - time.Nanosecond, path: "/old", wantPath: "/old"},
+		{advance: time.Nanosecond, path: "/old"},
+		// Clock going back is noticed as well
+		{advance: -time.Hour, path: "/old", wantPath: "/old"},
+	}
+
+	for i, step := range steps {
+		now = now.Add(step.advance)
+
+		pkg, err := resolver.ResolvePackage(context.Background(), step.path)
+		if step.wantPath == "" {
+			if !errors.Is(err, vanityurl.ErrPackageNotFound) {
+				t.Errorf("step %d: ResolvePackage(%s) = %v, %v; want not found", i, step.path, pkg, err)
+			}
+
+			continue
+		}
+
+		if err != nil || pkg.Path != step.wantPath {
+			t.Errorf("step %d: ResolvePackage(%s) = %v, %v; want %s", i, step.path, pkg.Path, err, step.wantPath)
+		}
+	}
+
+	list, err := resolver.(vanityurl.Lister).ListPackages(context.Background())
+	if err != nil || len(list) != 3 {
+		t.Errorf("ListPackages() = %v, %v; want 3 packages", list, err)
+	}
+}
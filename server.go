@@ -1,10 +1,17 @@
 package vanityurl
 
 import (
+	"bytes"
 	"cmp"
 	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -18,14 +25,60 @@ type ServerOptions struct {
 	Host string
 	// CacheAge for response Cache-Control header. Default is 24h.
 	CacheAge time.Duration
+	// NotFoundCacheAge for Cache-Control header of 404 responses. Not cached if zero.
+	NotFoundCacheAge time.Duration
+	// HealthPath for liveness endpoint. Disabled if empty.
+	HealthPath string
+	// ReadyPath for readiness endpoint. Disabled if empty.
+	ReadyPath string
+	// VersionPath for version endpoint. Disabled if empty.
+	VersionPath string
+	// Version reported by version endpoint.
+	Version string
+	// MetricsPath for Prometheus metrics endpoint. Disabled if empty.
+	// Requests of unlisted and private packages are counted under package="hidden".
+	MetricsPath string
+	// MaxPathLength of request URL path. Longer paths are rejected with 414. Unlimited if zero.
+	MaxPathLength int
+	// MaxSubpathDepth of package subpath. Deeper subpaths are rejected with 400. Unlimited if zero.
+	MaxSubpathDepth int
+	// Templates for rendering documents. Default is [DefaultTemplates].
+	Templates *Templates
+	// Authorizer allows requests to [Private] packages. Private packages are not found if nil.
+	Authorizer Authorizer
+	// IPRules allow or deny clients by IP address for package paths. All clients are allowed if empty.
+	IPRules []IPRule
+	// TrustedProxies allowed to set client IP for IPRules with Forwarded or X-Forwarded-For header.
+	TrustedProxies []netip.Prefix
+	// Logger for access decisions. Discarded if nil.
+	Logger *slog.Logger
+	// MiddlewareBrowsers makes [Server.Middleware] answer browser requests to package paths too.
+	// By default only go-get requests are answered.
+	MiddlewareBrowsers bool
 }
 
 // Server for Go package vanity urls that implements [http.Handler]
 type Server struct {
-	host     string
-	cacheAge time.Duration
+	host             string
+	cacheAge         time.Duration
+	notFoundCacheAge time.Duration
+	version          string
 
-	resolver Resolver
+	maxPathLength   int
+	maxSubpathDepth int
+
+	middlewareBrowsers bool
+
+	resolver  Resolver
+	templates *Templates
+	reserved  map[string]http.HandlerFunc
+	notReady  atomic.Bool
+	metrics   *metrics
+
+	authorizer     Authorizer
+	ipRules        []IPRule
+	trustedProxies []netip.Prefix
+	logger         *slog.Logger
 }
 
 // NewServer creates a new [Server] to serve Go vanity url endpoints.
@@ -38,11 +91,43 @@ func NewServer(resolver Resolver, opts *ServerOptions) *Server {
 		opts.CacheAge = defaultServerOptions.CacheAge
 	}
 
-	return &Server{
-		host:     opts.Host,
-		cacheAge: opts.CacheAge,
-		resolver: resolver,
+	srv := &Server{
+		host:      opts.Host,
+		cacheAge:  opts.CacheAge,
+		version:   opts.Version,
+		resolver:  resolver,
+		templates: cmp.Or(opts.Templates, defaultTemplates),
+		reserved:  map[string]http.HandlerFunc{},
+
+		authorizer:       opts.Authorizer,
+		notFoundCacheAge: max(opts.NotFoundCacheAge, 0),
+
+		ipRules:        slices.Clone(opts.IPRules),
+		trustedProxies: slices.Clone(opts.TrustedProxies),
+		logger:         cmp.Or(opts.Logger, slog.New(slog.NewTextHandler(io.Discard, nil))),
+
+		maxPathLength:   max(opts.MaxPathLength, 0),
+		maxSubpathDepth: max(opts.MaxSubpathDepth, 0),
+
+		middlewareBrowsers: opts.MiddlewareBrowsers,
+	}
+
+	for path, handler := range map[string]http.HandlerFunc{
+		opts.HealthPath:  srv.serveHealth,
+		opts.ReadyPath:   srv.serveReady,
+		opts.VersionPath: srv.serveVersion,
+		opts.MetricsPath: srv.serveMetrics,
+	} {
+		if path != "" {
+			srv.reserved[path] = handler
+		}
 	}
+
+	if opts.MetricsPath != "" {
+		srv.metrics = newMetrics()
+	}
+
+	return srv
 }
 
 // Host returns host used by server.
@@ -60,21 +145,161 @@ func (srv *Server) Resolver() Resolver {
 	return srv.resolver
 }
 
-// ServeHTTP implementation of [http.Handler].
+// ServeHTTP implementation of [http.Handler]. Serves both endpoints and packages.
 func (srv *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if handler, ok := srv.reserved[r.URL.Path]; ok {
+		handler(w, r)
+
+		return
+	}
+
+	srv.servePackage(w, r)
+}
+
+// Middleware returns [http.Handler] answering go-get requests for known packages and passing
+// all other requests to next untouched, so vanity urls can share a host with an existing site.
+// Browser requests to package paths are answered only if [ServerOptions.MiddlewareBrowsers] is set.
+// Enabled server endpoints are served as well.
+func (srv *Server) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if handler, ok := srv.reserved[r.URL.Path]; ok {
+			handler(w, r)
+
+			return
+		}
+
+		if !srv.middlewareBrowsers && !isGoGet(r) {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		srv.servePackageOr(w, r, next)
+	})
+}
+
+// PackagesHandler returns [http.Handler] serving only packages, without server endpoints.
+func (srv *Server) PackagesHandler() http.Handler {
+	return http.HandlerFunc(srv.servePackage)
+}
+
+// EndpointsHandler returns [http.Handler] serving only enabled server endpoints.
+func (srv *Server) EndpointsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler, ok := srv.reserved[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+
+			return
+		}
+
+		handler(w, r)
+	})
+}
+
+func (srv *Server) servePackage(w http.ResponseWriter, r *http.Request) {
+	srv.servePackageOr(w, r, nil)
+}
+
+// servePackageOr serves package document. Requests not matching any package are passed to next,
+// or answered with 404 if next is nil.
+func (srv *Server) servePackageOr(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	var (
+		pkgPath string
+		passed  bool
+	)
+
+	// Passed requests get original writer, so optional interfaces like http.Flusher are kept
+	origW := w
+
+	if srv.metrics != nil {
+		sw := &statusWriter{ResponseWriter: w}
+		w = sw
+
+		defer func() {
+			if !passed {
+				srv.metrics.observeRequest(sw.Status(), clientType(r), pkgPath)
+			}
+		}()
+	}
+
+	if srv.maxPathLength > 0 && len(r.URL.Path) > srv.maxPathLength {
+		if next != nil {
+			passed = true
+			next.ServeHTTP(origW, r)
+
+			return
+		}
+
+		http.Error(w, "URI Too Long", http.StatusRequestURITooLong)
+
+		return
+	}
+
+	start := time.Now()
 	pkg, err := srv.resolver.ResolvePackage(r.Context(), r.URL.Path)
+
+	// Resolver result is recorded before authorization hides private packages
+	srv.metrics.observeResolve(time.Since(start), err)
+
+	// Rules are checked against every path of resolved package, so aliases cannot bypass them
+	ipPaths := []string{r.URL.Path}
+	if err == nil || errors.Is(err, ErrPackageGone) {
+		ipPaths = aliasPaths(pkg, r.URL.Path)
+	}
+
+	if !srv.allowIP(r, ipPaths) {
+		if next != nil {
+			passed = true
+			next.ServeHTTP(origW, r)
+
+			return
+		}
+
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	var challenge string
+
+	// Private packages are not revealed to unauthorized clients
+	if (err == nil || errors.Is(err, ErrPackageGone)) && !srv.authorized(r, pkg) {
+		if challenger, ok := srv.authorizer.(Challenger); ok {
+			challenge = challenger.Challenge(r, pkg)
+		}
+
+		pkg, err = Package{}, ErrPackageNotFound
+	}
+
+	if challenge != "" {
+		w.Header().Set("WWW-Authenticate", challenge)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+
+		return
+	}
+
 	if errors.Is(err, ErrPackageNotFound) {
-		http.Error(w, "Package not found", http.StatusNotFound)
+		if next != nil {
+			passed = true
+			next.ServeHTTP(origW, r)
+
+			return
+		}
+
+		srv.serveNotFound(w, r)
 
 		return
-	} else if err != nil {
+	} else if err != nil && !errors.Is(err, ErrPackageGone) {
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 
 		return
 	}
 
-	w.Header().Add("Content-Type", "text/html; charset=utf-8")
-	w.Header().Add("Cache-Control", fmt.Sprintf("public, max-age=%d", srv.cacheAge/time.Second))
+	pkgPath = pkg.Path
+	if !pkg.Visibility.Listed() {
+		pkgPath = hiddenPackage
+	}
 
 	var subpath string
 
@@ -82,5 +307,144 @@ func (srv *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		subpath = r.URL.Path[len(pkg.Path)+1:]
 	}
 
-	_ = pkg.RenderDocument(w, cmp.Or(srv.host, r.Host), subpath)
+	setRequestInfo(r.Context(), pkg.Path, subpath)
+
+	if errors.Is(err, ErrPackageGone) {
+		http.Error(w, cmp.Or(pkg.RetiredReason, "Package gone"), http.StatusGone)
+
+		return
+	}
+
+	if srv.maxSubpathDepth > 0 && strings.Count(subpath, "/") >= srv.maxSubpathDepth {
+		http.Error(w, "Subpath too deep", http.StatusBadRequest)
+
+		return
+	}
+
+	// Shared caches must not keep documents of private packages
+	cacheScope := "public"
+	if pkg.Visibility == Private {
+		cacheScope = "private"
+	}
+
+	cacheAge := cmp.Or(pkg.CacheAge, srv.cacheAge)
+
+	// Documents are not cached past package expiry
+	if !pkg.ExpireAt.IsZero() {
+		cacheAge = max(min(cacheAge, time.Until(pkg.ExpireAt)), 0)
+	}
+
+	w.Header().Add("Cache-Control", fmt.Sprintf("%s, max-age=%d", cacheScope, cacheAge/time.Second))
+	w.Header().Add("Content-Type", "text/html; charset=utf-8")
+
+	for name, value := range pkg.Headers {
+		w.Header().Set(name, value)
+	}
+
+	// The go tool keeps using old path of moved package, so only browsers are redirected
+	if pkg.MovedTo != "" && !isGoGet(r) {
+		target := pkg.MovedTo
+		if subpath != "" {
+			target += "/" + subpath
+		}
+
+		http.Redirect(w, r, target, http.StatusMovedPermanently)
+
+		return
+	}
+
+	_ = srv.templates.RenderDocument(w, pkg, cmp.Or(srv.host, r.Host), subpath)
+}
+
+// serveNotFound answers the go tool with plain text and browsers with HTML page suggesting
+// closest packages, if resolver implements [Lister].
+func (srv *Server) serveNotFound(w http.ResponseWriter, r *http.Request) {
+	if srv.notFoundCacheAge > 0 {
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", srv.notFoundCacheAge/time.Second))
+	}
+
+	if isGoGet(r) {
+		http.Error(w, "Package not found", http.StatusNotFound)
+
+		return
+	}
+
+	var suggestions []Package
+
+	if lister, ok := srv.resolver.(Lister); ok {
+		if pset, err := lister.ListPackages(r.Context()); err == nil {
+			suggestions = suggestPackages(r.URL.Path, pset, maxSuggestions)
+		}
+	}
+
+	var buf bytes.Buffer
+
+	if err := srv.templates.RenderNotFound(&buf, cmp.Or(srv.host, r.Host), r.URL.Path, suggestions); err != nil {
+		http.Error(w, "Package not found", http.StatusNotFound)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusNotFound)
+
+	_, _ = buf.WriteTo(w)
+}
+
+// authorized reports whether request may access the package.
+func (srv *Server) authorized(r *http.Request, pkg Package) bool {
+	if pkg.Visibility != Private {
+		return true
+	}
+
+	return srv.authorizer != nil && srv.authorizer.Authorize(r, pkg)
+}
+
+// isGoGet reports whether request is made by the go tool.
+func isGoGet(r *http.Request) bool {
+	return r.URL.Query().Get("go-get") == "1"
+}
+
+func clientType(r *http.Request) string {
+	if isGoGet(r) {
+		return clientGoGet
+	}
+
+	return clientBrowser
+}
+
+// statusWriter records status code and size of the response.
+type statusWriter struct {
+	http.ResponseWriter
+	code  int
+	bytes int64
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+
+	return n, err
+}
+
+// Status code written to response. Defaults to 200.
+func (w *statusWriter) Status() int {
+	return cmp.Or(w.code, http.StatusOK)
+}
+
+// Unwrap returns original [http.ResponseWriter], used by [http.ResponseController].
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
 }
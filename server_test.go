@@ -73,6 +73,7 @@ func TestServerHandler(t *testing.T) {
 		name        string
 		srv         *vanityurl.Server
 		path        string
+		query       string
 		wantStatus  int
 		wantHeaders map[string]string
 		wantInBody  []string
@@ -84,12 +85,98 @@ func TestServerHandler(t *testing.T) {
 				nil,
 			),
 			path:       "/foo",
+			query:      "go-get=1",
 			wantStatus: http.StatusNotFound,
 			wantHeaders: map[string]string{
 				"Content-Type": "text/plain; charset=utf-8",
 			},
 			wantInBody: []string{"Package not found"},
 		},
+		{
+			name: "not_found_browser",
+			srv: vanityurl.NewServer(
+				mustResolver(t,
+					vanityurl.Package{Path: "/foo", RepositoryURL: "https://github.com/example/foo"},
+					vanityurl.Package{Path: "/foo-cli", RepositoryURL: "https://github.com/example/foo-cli"},
+					vanityurl.Package{Path: "/bar", RepositoryURL: "https://github.com/example/bar"},
+				),
+				&vanityurl.ServerOptions{Host: "go.example.com"},
+			),
+			path:       "/fooo",
+			wantStatus: http.StatusNotFound,
+			wantHeaders: map[string]string{
+				"Content-Type": "text/html; charset=utf-8",
+			},
+			wantInBody: []string{
+				`<p>Package go.example.com/fooo not found.</p>`,
+				`<p>Did you mean:</p>`,
+				`<li><a href="/foo">go.example.com/foo</a></li>`,
+				`<li><a href="/foo-cli">go.example.com/foo-cli</a></li>`,
+			},
+		},
+		{
+			name: "moved_browser",
+			srv: vanityurl.NewServer(
+				mustResolver(t, vanityurl.Package{
+					Path:          "/oldlib",
+					RepositoryURL: "https://github.com/example/oldlib",
+					MovedTo:       "/newlib",
+				}),
+				&vanityurl.ServerOptions{Host: "go.example.com"},
+			),
+			path:       "/oldlib/sub",
+			wantStatus: http.StatusMovedPermanently,
+			wantHeaders: map[string]string{
+				"Location": "/newlib/sub",
+			},
+		},
+		{
+			name: "moved_go_get",
+			srv: vanityurl.NewServer(
+				mustResolver(t, vanityurl.Package{
+					Path:          "/oldlib",
+					RepositoryURL: "https://github.com/example/oldlib",
+					MovedTo:       "/newlib",
+				}),
+				&vanityurl.ServerOptions{Host: "go.example.com"},
+			),
+			path:       "/oldlib/sub",
+			query:      "go-get=1",
+			wantStatus: http.StatusOK,
+			wantInBody: []string{
+				`<meta name="go-import" content="go.example.com/oldlib git https://github.com/example/oldlib">`,
+				`<p><strong>Deprecated:</strong> go.example.com/oldlib has moved to <a href="https://pkg.go.dev/go.example.com/newlib">go.example.com/newlib</a>.</p>`,
+			},
+		},
+		{
+			name: "retired",
+			srv: vanityurl.NewServer(
+				mustResolver(t, vanityurl.Package{
+					Path:          "/old",
+					RepositoryURL: "https://github.com/example/old",
+					Retired:       true,
+					RetiredReason: "Removed for legal reasons",
+				}),
+				nil,
+			),
+			path:       "/old/sub",
+			query:      "go-get=1",
+			wantStatus: http.StatusGone,
+			wantHeaders: map[string]string{
+				"Content-Type": "text/plain; charset=utf-8",
+			},
+			wantInBody: []string{"Removed for legal reasons"},
+		},
+		{
+			name: "not_found_escaped",
+			srv: vanityurl.NewServer(
+				failingResolver{vanityurl.ErrPackageNotFound},
+				&vanityurl.ServerOptions{Host: "go.example.com"},
+			),
+			path:       "/%3Cscript%3E",
+			wantStatus: http.StatusNotFound,
+			wantInBody: []string{`<p>Package go.example.com/&lt;script&gt; not found.</p>`},
+		},
 		{
 			name: "resolver_error",
 			srv: vanityurl.NewServer(
@@ -171,6 +258,121 @@ func TestServerHandler(t *testing.T) {
 				`</html>`,
 			},
 		},
+		{
+			name: "alias",
+			srv: vanityurl.NewServer(
+				mustResolver(t, vanityurl.Package{
+					Path:          "/util",
+					VCS:           vanityurl.Git,
+					Display:       "util_display",
+					RepositoryURL: "https://git.example.com/util",
+					Aliases:       []string{"/x/util"},
+				}),
+				&vanityurl.ServerOptions{Host: "go.example.com"},
+			),
+			path:       "/x/util/sub",
+			query:      "go-get=1",
+			wantStatus: http.StatusOK,
+			wantInBody: []string{
+				`<meta name="go-import" content="go.example.com/x/util git https://git.example.com/util">`,
+				`<meta name="go-source" content="go.example.com/x/util util_display">`,
+				`<meta http-equiv="refresh" content="0; url=https://pkg.go.dev/go.example.com/util/sub">`,
+			},
+		},
+		{
+			name: "package_cache_age_and_headers",
+			srv: vanityurl.NewServer(
+				mustResolver(t, vanityurl.Package{
+					Path:          "/foo",
+					RepositoryURL: "https://github.com/example/foo",
+					CacheAge:      5 * time.Minute,
+					Headers:       map[string]string{"X-Robots-Tag": "noindex"},
+				}),
+				&vanityurl.ServerOptions{CacheAge: time.Hour},
+			),
+			path:       "/foo",
+			query:      "go-get=1",
+			wantStatus: http.StatusOK,
+			wantHeaders: map[string]string{
+				"Cache-Control": "public, max-age=300",
+				"X-Robots-Tag":  "noindex",
+			},
+		},
+		{
+			name: "cache_age_until_expiry",
+			srv: vanityurl.NewServer(
+				mustResolver(t, vanityurl.Package{
+					Path:          "/foo",
+					RepositoryURL: "https://github.com/example/foo",
+					ExpireAt:      time.Now().Add(time.Minute + time.Second/2),
+				}),
+				&vanityurl.ServerOptions{CacheAge: time.Hour},
+			),
+			path:       "/foo",
+			query:      "go-get=1",
+			wantStatus: http.StatusOK,
+			wantHeaders: map[string]string{
+				"Cache-Control": "public, max-age=60",
+			},
+		},
+		{
+			name: "not_found_cache_age",
+			srv: vanityurl.NewServer(
+				failingResolver{vanityurl.ErrPackageNotFound},
+				&vanityurl.ServerOptions{NotFoundCacheAge: time.Minute},
+			),
+			path:       "/foo",
+			query:      "go-get=1",
+			wantStatus: http.StatusNotFound,
+			wantHeaders: map[string]string{
+				"Cache-Control": "public, max-age=60",
+			},
+		},
+		{
+			name: "path_too_long",
+			srv: vanityurl.NewServer(
+				mustResolver(t, vanityurl.Package{
+					Path:          "/foo",
+					RepositoryURL: "https://github.com/example/foo",
+				}),
+				&vanityurl.ServerOptions{
+					MaxPathLength: 8,
+				},
+			),
+			path:       "/foo/bar/baz",
+			wantStatus: http.StatusRequestURITooLong,
+			wantInBody: []string{"URI Too Long"},
+		},
+		{
+			name: "subpath_too_deep",
+			srv: vanityurl.NewServer(
+				mustResolver(t, vanityurl.Package{
+					Path:          "/foo",
+					RepositoryURL: "https://github.com/example/foo",
+				}),
+				&vanityurl.ServerOptions{
+					MaxSubpathDepth: 2,
+				},
+			),
+			path:       "/foo/a/b/c",
+			wantStatus: http.StatusBadRequest,
+			wantInBody: []string{"Subpath too deep"},
+		},
+		{
+			name: "subpath_max_depth",
+			srv: vanityurl.NewServer(
+				mustResolver(t, vanityurl.Package{
+					Path:          "/foo",
+					RepositoryURL: "https://github.com/example/foo",
+				}),
+				&vanityurl.ServerOptions{
+					MaxPathLength:   16,
+					MaxSubpathDepth: 2,
+				},
+			),
+			path:       "/foo/a/b",
+			wantStatus: http.StatusOK,
+		},
 	}
 
 	for _, tc := range tt {
@@ -182,7 +384,18 @@ func TestServerHandler(t *testing.T) {
 				t.Fatalf("got error while making request url: %v", err)
 			}
 
-			res, err := http.Get(path) //nolint:gosec
+			if tc.query != "" {
+				path += "?" + tc.query
+			}
+
+			// Redirects are checked, not followed
+			client := &http.Client{
+				CheckRedirect: func(*http.Request, []*http.Request) error {
+					return http.ErrUseLastResponse
+				},
+			}
+
+			res, err := client.Get(path) //nolint:noctx
 			if err != nil {
 				t.Fatalf("got error while making request: %v", err)
 			}
@@ -212,3 +425,348 @@ func TestServerHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestServerEndpoints(t *testing.T) {
+	resolver := mustResolver(t, vanityurl.Package{
+		Path:          "/foo",
+		RepositoryURL: "https://github.com/example/foo",
+	})
+
+	opts := &vanityurl.ServerOptions{
+		HealthPath:  "/healthz",
+		ReadyPath:   "/readyz",
+		VersionPath: "/version",
+		Version:     "v1.2.3",
+	}
+
+	tt := []struct {
+		name       string
+		srv        *vanityurl.Server
+		notReady   bool
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "health",
+			srv:        vanityurl.NewServer(resolver, opts),
+			path:       "/healthz",
+			wantStatus: http.StatusOK,
+			wantBody:   "OK\n",
+		},
+		{
+			name:       "ready",
+			srv:        vanityurl.NewServer(resolver, opts),
+			path:       "/readyz",
+			wantStatus: http.StatusOK,
+			wantBody:   "OK\n",
+		},
+		{
+			name:       "not_ready",
+			srv:        vanityurl.NewServer(resolver, opts),
+			notReady:   true,
+			path:       "/readyz",
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody:   "Service Unavailable\n",
+		},
+		{
+			name:       "resolver_unhealthy",
+			srv:        vanityurl.NewServer(vanityurl.NewMultiResolver(resolver, failingResolver{os.ErrClosed}), opts),
+			path:       "/readyz",
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody:   "Service Unavailable\n",
+		},
+		{
+			name:       "version",
+			srv:        vanityurl.NewServer(resolver, opts),
+			path:       "/version",
+			wantStatus: http.StatusOK,
+			wantBody:   `{"version":"v1.2.3"}` + "\n",
+		},
+		{
+			name:       "disabled",
+			srv:        vanityurl.NewServer(resolver, nil),
+			path:       "/healthz?go-get=1",
+			wantStatus: http.StatusNotFound,
+			wantBody:   "Package not found\n",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.srv.SetReady(!tc.notReady)
+
+			rec := httptest.NewRecorder()
+			tc.srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+
+			if rec.Code != tc.wantStatus {
+				t.Errorf("Server.ServeHTTP() status = %d; wantStatus = %d", rec.Code, tc.wantStatus)
+			}
+
+			if body := rec.Body.String(); body != tc.wantBody {
+				t.Errorf("Server.ServeHTTP() body = %q; wantBody = %q", body, tc.wantBody)
+			}
+		})
+	}
+}
+
+func TestServerNotFoundEscapesHost(t *testing.T) {
+	srv := vanityurl.NewServer(
+		mustResolver(t, vanityurl.Package{Path: "/foo", RepositoryURL: "https://github.com/example/foo"}),
+		nil,
+	)
+
+	r := httptest.NewRequest(http.MethodGet, "/fooo", nil)
+	r.Host = "<script>x</script>"
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, r)
+
+	body := rec.Body.String()
+
+	if strings.Contains(body, "<script>") {
+		t.Errorf("Server.ServeHTTP() body = %s; want escaped host", body)
+	}
+
+	if want := `<li><a href="/foo">&lt;script&gt;x&lt;/script&gt;/foo</a></li>`; !strings.Contains(body, want) {
+		t.Errorf("Server.ServeHTTP() body = %s; want in body = %s", body, want)
+	}
+}
+
+func TestServerMetrics(t *testing.T) {
+	srv := vanityurl.NewServer(
+		vanityurl.NewMultiResolver(
+			mustResolver(t, vanityurl.Package{
+				Path:          "/foo",
+				RepositoryURL: "https://github.com/example/foo",
+			}, vanityurl.Package{
+				Path:          "/secret",
+				RepositoryURL: "https://github.com/example/secret",
+				Visibility:    vanityurl.Private,
+			}, vanityurl.Package{
+				Path:          "/beta",
+				RepositoryURL: "https://github.com/example/beta",
+				Visibility:    vanityurl.Unlisted,
+			}),
+		),
+		&vanityurl.ServerOptions{
+			MetricsPath: "/metrics",
+		},
+	)
+
+	for _, target := range []string{"/foo?go-get=1", "/foo/bar?go-get=1", "/foo", "/baz", "/secret", "/beta?go-get=1"} {
+		srv.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+	}
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if want := "text/plain; version=0.0.4; charset=utf-8"; rec.Header().Get("Content-Type") != want {
+		t.Errorf("Server.ServeHTTP() Header[Content-Type] = %s; want = %s", rec.Header().Get("Content-Type"), want)
+	}
+
+	wantLines := []string{
+		`# TYPE vanityurl_requests_total counter`,
+		`vanityurl_requests_total{code="200",client="browser"} 1`,
+		`vanityurl_requests_total{code="200",client="go-get"} 3`,
+		`vanityurl_requests_total{code="404",client="browser"} 2`,
+		`# TYPE vanityurl_package_requests_total counter`,
+		`vanityurl_package_requests_total{package="/foo",client="browser"} 1`,
+		`vanityurl_package_requests_total{package="/foo",client="go-get"} 2`,
+		`vanityurl_package_requests_total{package="hidden",client="go-get"} 1`,
+		`# TYPE vanityurl_resolve_duration_seconds histogram`,
+		`vanityurl_resolve_duration_seconds_bucket{result="found",le="+Inf"} 5`,
+		`vanityurl_resolve_duration_seconds_count{result="found"} 5`,
+		`vanityurl_resolve_duration_seconds_bucket{result="not_found",le="+Inf"} 1`,
+		`vanityurl_resolve_duration_seconds_count{result="not_found"} 1`,
+	}
+
+	body := rec.Body.String()
+
+	for _, line := range wantLines {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("Server.ServeHTTP() metrics = %s; want line = %s", body, line)
+		}
+	}
+
+	if strings.Contains(body, `package="/baz"`) || strings.Contains(body, `package="/secret"`) ||
+		strings.Contains(body, `package="/beta"`) {
+		t.Errorf("Server.ServeHTTP() metrics = %s; unexpected label for unknown or hidden package", body)
+	}
+}
+
+func TestServerSplitHandlers(t *testing.T) {
+	srv := vanityurl.NewServer(
+		mustResolver(t, vanityurl.Package{
+			Path:          "/foo",
+			RepositoryURL: "https://github.com/example/foo",
+		}),
+		&vanityurl.ServerOptions{
+			HealthPath: "/healthz",
+		},
+	)
+
+	tt := []struct {
+		name       string
+		handler    http.Handler
+		path       string
+		wantStatus int
+	}{
+		{name: "packages_package", handler: srv.PackagesHandler(), path: "/foo", wantStatus: http.StatusOK},
+		{name: "packages_endpoint", handler: srv.PackagesHandler(), path: "/healthz", wantStatus: http.StatusNotFound},
+		{name: "endpoints_endpoint", handler: srv.EndpointsHandler(), path: "/healthz", wantStatus: http.StatusOK},
+		{name: "endpoints_package", handler: srv.EndpointsHandler(), path: "/foo", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			tc.handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+
+			if rec.Code != tc.wantStatus {
+				t.Errorf("ServeHTTP() status = %d; wantStatus = %d", rec.Code, tc.wantStatus)
+			}
+		})
+	}
+}
+
+func TestServerMiddleware(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+		_, _ = io.WriteString(w, "website")
+	})
+
+	newServer := func(browsers bool) *vanityurl.Server {
+		return vanityurl.NewServer(
+			mustResolver(t, vanityurl.Package{
+				Path:          "/foo",
+				RepositoryURL: "https://github.com/example/foo",
+			}),
+			&vanityurl.ServerOptions{
+				Host:               "acme.dev",
+				HealthPath:         "/healthz",
+				MetricsPath:        "/metrics",
+				MaxPathLength:      32,
+				MiddlewareBrowsers: browsers,
+			},
+		)
+	}
+
+	tt := []struct {
+		name       string
+		browsers   bool
+		target     string
+		wantStatus int
+		wantNext   bool
+	}{
+		{name: "go_get_package", target: "/foo/bar?go-get=1", wantStatus: http.StatusOK},
+		{name: "go_get_unknown", target: "/about?go-get=1", wantStatus: http.StatusTeapot, wantNext: true},
+		{name: "go_get_too_long", target: "/" + strings.Repeat("a", 40) + "?go-get=1", wantStatus: http.StatusTeapot, wantNext: true},
+		{name: "browser_package", target: "/foo", wantStatus: http.StatusTeapot, wantNext: true},
+		{name: "browser_package_enabled", browsers: true, target: "/foo", wantStatus: http.StatusOK},
+		{name: "browser_unknown_enabled", browsers: true, target: "/about", wantStatus: http.StatusTeapot, wantNext: true},
+		{name: "endpoint", target: "/healthz", wantStatus: http.StatusOK},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := newServer(tc.browsers)
+
+			rec := httptest.NewRecorder()
+			srv.Middleware(next).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.target, nil))
+
+			if rec.Code != tc.wantStatus {
+				t.Errorf("ServeHTTP() status = %d; wantStatus = %d", rec.Code, tc.wantStatus)
+			}
+
+			if gotNext := rec.Body.String() == "website"; gotNext != tc.wantNext {
+				t.Errorf("ServeHTTP() passed to next = %v; want %v", gotNext, tc.wantNext)
+			}
+
+			if tc.target == "/healthz" {
+				return
+			}
+
+			// Passed requests are not counted as package requests
+			metrics := httptest.NewRecorder()
+			srv.ServeHTTP(metrics, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+			if counted := strings.Contains(metrics.Body.String(), "vanityurl_requests_total{"); counted == tc.wantNext {
+				t.Errorf("request counted in metrics = %v; want %v", counted, !tc.wantNext)
+			}
+		})
+	}
+}
+
+func TestServerVisibility(t *testing.T) {
+	resolver := mustResolver(t,
+		vanityurl.Package{Path: "/foo", RepositoryURL: "https://github.com/example/foo"},
+		vanityurl.Package{Path: "/foo-beta", RepositoryURL: "https://github.com/example/foo-beta", Visibility: vanityurl.Unlisted},
+		vanityurl.Package{Path: "/foo-internal", RepositoryURL: "https://github.com/example/foo-internal", Visibility: vanityurl.Private},
+	)
+
+	authorizer := vanityurl.AuthorizerFunc(func(r *http.Request, _ vanityurl.Package) bool {
+		return r.Header.Get("Authorization") == "Bearer secret"
+	})
+
+	tt := []struct {
+		name          string
+		authorizer    vanityurl.Authorizer
+		target        string
+		authorization string
+		wantStatus    int
+		wantHeaders   map[string]string
+		wantInBody    string
+		wantNotInBody string
+	}{
+		{name: "unlisted", target: "/foo-beta?go-get=1", wantStatus: http.StatusOK},
+		{name: "private_without_authorizer", target: "/foo-internal?go-get=1", authorization: "Bearer secret", wantStatus: http.StatusNotFound},
+		{name: "private_unauthorized", authorizer: authorizer, target: "/foo-internal/sub?go-get=1", wantStatus: http.StatusNotFound},
+		{name: "private_authorized", authorizer: authorizer, target: "/foo-internal/sub?go-get=1", authorization: "Bearer secret", wantStatus: http.StatusOK},
+		{name: "private_challenge", authorizer: challengeAuthorizer{authorizer}, target: "/foo-internal?go-get=1", wantStatus: http.StatusUnauthorized, wantHeaders: map[string]string{"WWW-Authenticate": "Basic"}},
+		{name: "private_cache_control", authorizer: authorizer, target: "/foo-internal?go-get=1", authorization: "Bearer secret", wantStatus: http.StatusOK, wantHeaders: map[string]string{"Cache-Control": "private, max-age=86400"}},
+		{name: "suggestions", authorizer: authorizer, target: "/fooo", authorization: "Bearer secret", wantStatus: http.StatusNotFound, wantInBody: "/foo", wantNotInBody: "/foo-"},
+		{name: "suggestions_long_path", target: "/fooo/" + strings.Repeat("x", 1<<20), wantStatus: http.StatusNotFound, wantInBody: `href="/foo"`},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := vanityurl.NewServer(resolver, &vanityurl.ServerOptions{Authorizer: tc.authorizer})
+
+			r := httptest.NewRequest(http.MethodGet, tc.target, nil)
+			if tc.authorization != "" {
+				r.Header.Set("Authorization", tc.authorization)
+			}
+
+			w := httptest.NewRecorder()
+
+			srv.ServeHTTP(w, r)
+
+			if w.Code != tc.wantStatus {
+				t.Errorf("status = %d; want = %d", w.Code, tc.wantStatus)
+			}
+
+			for name, want := range tc.wantHeaders {
+				if got := w.Header().Get(name); got != want {
+					t.Errorf("header %s = %s; want = %s", name, got, want)
+				}
+			}
+
+			if !strings.Contains(w.Body.String(), tc.wantInBody) {
+				t.Errorf("body = %s; want in body = %s", w.Body.String(), tc.wantInBody)
+			}
+
+			if tc.wantNotInBody != "" && strings.Contains(w.Body.String(), tc.wantNotInBody) {
+				t.Errorf("body = %s; want not in body = %s", w.Body.String(), tc.wantNotInBody)
+			}
+		})
+	}
+}
+
+type challengeAuthorizer struct {
+	vanityurl.Authorizer
+}
+
+func (challengeAuthorizer) Challenge(*http.Request, vanityurl.Package) string {
+	return "Basic"
+}
@@ -0,0 +1,97 @@
+package vanityurl
+
+import (
+	"cmp"
+	"slices"
+)
+
+const (
+	// maxSuggestions shown on not found page.
+	maxSuggestions = 3
+	// maxSuggestDistance is edit distance still considered a typo.
+	maxSuggestDistance = 3
+	// minSuggestPrefix is shared prefix length, including leading slash, considered related.
+	minSuggestPrefix = 4
+	// maxSuggestPath is length of request path compared to packages, longer paths are cut.
+	maxSuggestPath = 256
+)
+
+// suggestPackages returns listed packages closest to a given path by edit distance and shared prefix.
+// Path is cut to maxSuggestPath bytes, so cost does not grow with request length.
+func suggestPackages(path string, pset []Package, limit int) []Package {
+	path = path[:min(len(path), maxSuggestPath)]
+
+	type candidate struct {
+		pkg   Package
+		score int
+	}
+
+	var candidates []candidate
+
+	for _, pkg := range pset {
+		if pkg.Retired || !pkg.Visibility.Listed() {
+			continue
+		}
+
+		distance := editDistance(path, pkg.Path)
+		prefix := sharedPrefix(path, pkg.Path)
+
+		if distance > maxSuggestDistance && prefix < minSuggestPrefix {
+			continue
+		}
+
+		candidates = append(candidates, candidate{
+			pkg:   pkg,
+			score: distance - prefix,
+		})
+	}
+
+	slices.SortFunc(candidates, func(a, b candidate) int {
+		return cmp.Or(cmp.Compare(a.score, b.score), cmp.Compare(a.pkg.Path, b.pkg.Path))
+	})
+
+	suggestions := make([]Package, 0, min(len(candidates), limit))
+	for _, c := range candidates[:min(len(candidates), limit)] {
+		suggestions = append(suggestions, c.pkg)
+	}
+
+	return suggestions
+}
+
+// editDistance is Levenshtein distance between two strings in bytes.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+
+		prev, curr = curr, prev
+	}
+
+	return prev[len(b)]
+}
+
+// sharedPrefix length of two strings in bytes.
+func sharedPrefix(a, b string) int {
+	n := 0
+
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+
+	return n
+}
@@ -0,0 +1,195 @@
+package vanityurl
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+	"text/template"
+)
+
+// Template names which can be replaced with custom templates.
+const (
+	// TemplateHead renders 'go-import' and 'go-source' meta elements. Executed with [DocumentData].
+	TemplateHead = "head"
+	// TemplateDocument renders full HTML document of a package. Executed with [DocumentData].
+	TemplateDocument = "document"
+	// TemplateIndex renders HTML document listing packages. Executed with [IndexData].
+	TemplateIndex = "index"
+	// TemplateNotFound renders HTML document for unknown paths. Executed with [NotFoundData].
+	TemplateNotFound = "notfound"
+
+	// TemplateExt is extension of template files parsed by [ParseTemplatesFS].
+	TemplateExt = ".gohtml"
+)
+
+//nolint:gochecknoglobals
+var (
+	templateNames = []string{TemplateHead, TemplateDocument, TemplateIndex, TemplateNotFound}
+
+	defaultTemplates = &Templates{tmpl: pkgTmpl}
+)
+
+// DocumentData passed to [TemplateDocument] and [TemplateHead] templates.
+type DocumentData struct {
+	// Package being rendered.
+	Package Package
+	// Host of packages, e.g. "go.example.com".
+	Host string
+	// Subpath of requested package path without leading slash. Always empty for [TemplateHead]
+	// rendered with [Templates.RenderHead].
+	Subpath string
+}
+
+// IndexData passed to [TemplateIndex] template.
+type IndexData struct {
+	// Packages sorted by path.
+	Packages []Package
+	// Host of packages, e.g. "go.example.com".
+	Host string
+}
+
+// NotFoundData passed to [TemplateNotFound] template.
+type NotFoundData struct {
+	// Host of packages, e.g. "go.example.com".
+	Host string
+	// Path requested, e.g. "/foo/bar". It is sent by client, so it must be escaped with html function.
+	Path string
+	// Suggestions of closest packages, best first. Empty if resolver does not implement [Lister].
+	Suggestions []Package
+}
+
+// Templates for rendering package documents and pages.
+type Templates struct {
+	tmpl *template.Template
+}
+
+// DefaultTemplates returns embedded templates.
+func DefaultTemplates() *Templates {
+	return defaultTemplates
+}
+
+// NewTemplates replaces default templates with templates of the same name defined in custom.
+// Templates missing in custom are taken from defaults, so e.g. default document renders custom head.
+// Returns [ErrInvalidTemplate] if templates fail to render.
+func NewTemplates(custom *template.Template) (*Templates, error) {
+	if custom == nil {
+		return defaultTemplates, nil
+	}
+
+	tmpl, err := custom.Clone()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidTemplate, err)
+	}
+
+	for _, name := range templateNames {
+		if tmpl.Lookup(name) != nil {
+			continue
+		}
+
+		if _, err := tmpl.AddParseTree(name, pkgTmpl.Lookup(name).Tree); err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", ErrInvalidTemplate, name, err)
+		}
+	}
+
+	return newValidTemplates(tmpl)
+}
+
+// ParseTemplatesFS replaces default templates with files named after templates, e.g. "document.gohtml".
+// Missing files are taken from defaults. Returns [ErrInvalidTemplate] if templates fail to parse or render.
+func ParseTemplatesFS(fsys fs.FS) (*Templates, error) {
+	tmpl, err := pkgTmpl.Clone()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range templateNames {
+		content, err := fs.ReadFile(fsys, name+TemplateExt)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		if _, err := tmpl.New(name).Parse(string(content)); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidTemplate, err)
+		}
+	}
+
+	return newValidTemplates(tmpl)
+}
+
+// newValidTemplates checks that every template renders with sample data,
+// and that document still contains meta elements required by the go tool.
+func newValidTemplates(tmpl *template.Template) (*Templates, error) {
+	templates := &Templates{tmpl: tmpl}
+
+	pkg := Package{
+		Path:          "/example",
+		VCS:           Git,
+		Display:       DetectDisplay("https://github.com/example/example"),
+		RepositoryURL: "https://github.com/example/example",
+		Description:   "Example package",
+		Homepage:      "https://example.com",
+		License:       "MIT",
+		Owner:         "example-team",
+		Tags:          []string{"example"},
+	}
+
+	var doc bytes.Buffer
+
+	for name, render := range map[string]func() error{
+		TemplateHead:     func() error { return templates.RenderHead(io.Discard, pkg, "go.example.com") },
+		TemplateDocument: func() error { return templates.RenderDocument(&doc, pkg, "go.example.com", "sub") },
+		TemplateIndex:    func() error { return templates.RenderIndex(io.Discard, "go.example.com", []Package{pkg}) },
+		TemplateNotFound: func() error {
+			return templates.RenderNotFound(io.Discard, "go.example.com", "/exampel", []Package{pkg})
+		},
+	} {
+		if err := render(); err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", ErrInvalidTemplate, name, err)
+		}
+	}
+
+	if !strings.Contains(doc.String(), "go.example.com/example git https://github.com/example/example") {
+		return nil, fmt.Errorf("%w: %s: missing go-import meta element", ErrInvalidTemplate, TemplateDocument)
+	}
+
+	return templates, nil
+}
+
+// RenderHead 'go-import' and 'go-source' HTML meta elements of the package
+func (t *Templates) RenderHead(wr io.Writer, pkg Package, host string) error {
+	return t.tmpl.ExecuteTemplate(wr, TemplateHead, DocumentData{
+		Package: pkg,
+		Host:    host,
+	})
+}
+
+// RenderDocument full HTML document of the package.
+func (t *Templates) RenderDocument(wr io.Writer, pkg Package, host, subpath string) error {
+	return t.tmpl.ExecuteTemplate(wr, TemplateDocument, DocumentData{
+		Package: pkg,
+		Host:    host,
+		Subpath: subpath,
+	})
+}
+
+// RenderIndex full HTML document listing a given packages.
+func (t *Templates) RenderIndex(wr io.Writer, host string, pset []Package) error {
+	return t.tmpl.ExecuteTemplate(wr, TemplateIndex, IndexData{
+		Packages: pset,
+		Host:     host,
+	})
+}
+
+// RenderNotFound full HTML document for unknown path with suggested packages.
+func (t *Templates) RenderNotFound(wr io.Writer, host, path string, suggestions []Package) error {
+	return t.tmpl.ExecuteTemplate(wr, TemplateNotFound, NotFoundData{
+		Host:        host,
+		Path:        path,
+		Suggestions: suggestions,
+	})
+}
@@ -0,0 +1,171 @@
+package vanityurl_test
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"text/template"
+
+	"go.wamod.dev/vanityurl"
+)
+
+func TestNewTemplates(t *testing.T) {
+	pkg := vanityurl.Package{
+		Path:          "/foo",
+		VCS:           vanityurl.Git,
+		Display:       "display",
+		RepositoryURL: "https://github.com/example/foo",
+	}
+
+	tt := []struct {
+		name        string
+		custom      string
+		wantErr     bool
+		wantElement []string
+	}{
+		{
+			name: "default",
+			wantElement: []string{
+				`<meta name="go-import" content="go.example.com/foo git https://github.com/example/foo">`,
+				`Nothing to see here;`,
+			},
+		},
+		{
+			name:   "custom_head",
+			custom: `{{define "head"}}<meta name="go-import" content="{{.Host}}{{.Package.Path}} {{.Package.VCS}} {{.Package.RepositoryURL}}"><meta property="og:title" content="{{.Package.Path}}">{{end}}`,
+			wantElement: []string{
+				`<meta property="og:title" content="/foo">`,
+				`Nothing to see here;`,
+			},
+		},
+		{
+			name:    "unknown_field",
+			custom:  `{{define "notfound"}}{{.Missing}}{{end}}`,
+			wantErr: true,
+		},
+		{
+			name:    "missing_go_import",
+			custom:  `{{define "document"}}<html></html>{{end}}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var custom *template.Template
+
+			if tc.custom != "" {
+				custom = template.Must(template.New("custom").Parse(tc.custom))
+			}
+
+			templates, err := vanityurl.NewTemplates(custom)
+			if tc.wantErr {
+				if !errors.Is(err, vanityurl.ErrInvalidTemplate) {
+					t.Errorf("NewTemplates() = %v; want %v", err, vanityurl.ErrInvalidTemplate)
+				}
+
+				return
+			} else if err != nil {
+				t.Fatalf("NewTemplates() = %v", err)
+			}
+
+			buf := bytes.NewBuffer(nil)
+
+			if err := templates.RenderDocument(buf, pkg, "go.example.com", ""); err != nil {
+				t.Fatalf("Templates.RenderDocument() = %v", err)
+			}
+
+			for _, element := range tc.wantElement {
+				if !strings.Contains(buf.String(), element) {
+					t.Errorf("Templates.RenderDocument() = %s; expected element in output = %s", buf.String(), element)
+				}
+			}
+		})
+	}
+}
+
+func TestParseTemplatesFS(t *testing.T) {
+	tt := []struct {
+		name    string
+		fsys    fstest.MapFS
+		wantErr bool
+		want    string
+	}{
+		{
+			name: "empty",
+			fsys: fstest.MapFS{},
+			want: `<title>Package not found</title>`,
+		},
+		{
+			name: "custom_notfound",
+			fsys: fstest.MapFS{
+				"notfound.gohtml": {Data: []byte(`<h1>{{.Path}} is not ours</h1>`)},
+				"unrelated.txt":   {Data: []byte(`{{`)},
+			},
+			want: `<h1>/bar is not ours</h1>`,
+		},
+		{
+			name: "parse_error",
+			fsys: fstest.MapFS{
+				"index.gohtml": {Data: []byte(`{{range .Packages}}`)},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			templates, err := vanityurl.ParseTemplatesFS(tc.fsys)
+			if tc.wantErr {
+				if !errors.Is(err, vanityurl.ErrInvalidTemplate) {
+					t.Errorf("ParseTemplatesFS() = %v; want %v", err, vanityurl.ErrInvalidTemplate)
+				}
+
+				return
+			} else if err != nil {
+				t.Fatalf("ParseTemplatesFS() = %v", err)
+			}
+
+			buf := bytes.NewBuffer(nil)
+
+			if err := templates.RenderNotFound(buf, "go.example.com", "/bar", nil); err != nil {
+				t.Fatalf("Templates.RenderNotFound() = %v", err)
+			}
+
+			if !strings.Contains(buf.String(), tc.want) {
+				t.Errorf("Templates.RenderNotFound() = %s; want in output = %s", buf.String(), tc.want)
+			}
+		})
+	}
+}
+
+func TestServerTemplates(t *testing.T) {
+	templates, err := vanityurl.ParseTemplatesFS(fstest.MapFS{
+		"document.gohtml": {Data: []byte(`<html><head>{{template "head" .}}</head><body>Branded {{.Subpath}}</body></html>`)},
+	})
+	if err != nil {
+		t.Fatalf("ParseTemplatesFS() = %v", err)
+	}
+
+	srv := vanityurl.NewServer(
+		mustResolver(t, vanityurl.Package{
+			Path:          "/foo",
+			RepositoryURL: "https://github.com/example/foo",
+		}),
+		&vanityurl.ServerOptions{
+			Host:      "go.example.com",
+			Templates: templates,
+		},
+	)
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/foo/bar", nil))
+
+	if want := "<body>Branded bar</body>"; !strings.Contains(rec.Body.String(), want) {
+		t.Errorf("Server.ServeHTTP() body = %s; want in body = %s", rec.Body.String(), want)
+	}
+}
@@ -0,0 +1,74 @@
+package vanityurl
+
+import (
+	"cmp"
+	"net/http"
+)
+
+//nolint:gochecknoglobals
+var (
+	visibilityMapStrValues = map[Visibility]string{
+		Public:   "public",
+		Unlisted: "unlisted",
+		Private:  "private",
+	}
+	visibilityMapStrKeys = map[string]Visibility{
+		"public":   Public,
+		"unlisted": Unlisted,
+		"private":  Private,
+	}
+)
+
+// Visibility of a package. Allowed are [Public], [Unlisted] and [Private].
+type Visibility uint8
+
+const (
+	// Public package is resolved and listed. It is the default.
+	Public Visibility = iota
+	// Unlisted package is resolved, but left out of package listings like suggestions and index pages.
+	Unlisted
+	// Private package is resolved only for requests allowed by [ServerOptions.Authorizer],
+	// and answered as not found otherwise. It is never listed.
+	Private
+)
+
+// Listed reports whether package with the visibility may appear in package listings.
+func (v Visibility) Listed() bool {
+	return v == Public
+}
+
+// String representation for visibility. Unknown values return "unspecified".
+func (v Visibility) String() string {
+	return cmp.Or(visibilityMapStrValues[v], "unspecified")
+}
+
+// ParseVisibility from string. Returns [ErrInvalidVisibility] when failed.
+func ParseVisibility(str string) (Visibility, error) {
+	v, ok := visibilityMapStrKeys[str]
+	if !ok {
+		return 0, ErrInvalidVisibility
+	}
+
+	return v, nil
+}
+
+// Authorizer decides whether a request may access a [Private] package.
+type Authorizer interface {
+	// Authorize reports whether request is allowed to resolve the package.
+	Authorize(r *http.Request, pkg Package) bool
+}
+
+// AuthorizerFunc is an adapter to use ordinary functions as [Authorizer].
+type AuthorizerFunc func(r *http.Request, pkg Package) bool
+
+// Authorize calls f(r, pkg).
+func (f AuthorizerFunc) Authorize(r *http.Request, pkg Package) bool {
+	return f(r, pkg)
+}
+
+// Challenger can be implemented by [Authorizer] to answer unauthorized requests to [Private]
+// packages with 401 Unauthorized instead of not found.
+type Challenger interface {
+	// Challenge returns WWW-Authenticate header value, or empty string to answer as not found.
+	Challenge(r *http.Request, pkg Package) string
+}
@@ -0,0 +1,59 @@
+package vanityurl_test
+
+import (
+	"testing"
+
+	"go.wamod.dev/vanityurl"
+)
+
+func TestParseVisibility(t *testing.T) {
+	tt := []struct {
+		name    string
+		str     string
+		want    vanityurl.Visibility
+		wantErr bool
+	}{
+		{
+			name:    "empty",
+			str:     "",
+			wantErr: true,
+		},
+		{
+			name:    "unknown",
+			str:     "hidden",
+			wantErr: true,
+		},
+		{
+			name: "public",
+			str:  "public",
+			want: vanityurl.Public,
+		},
+		{
+			name: "unlisted",
+			str:  "unlisted",
+			want: vanityurl.Unlisted,
+		},
+		{
+			name: "private",
+			str:  "private",
+			want: vanityurl.Private,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := vanityurl.ParseVisibility(tc.str)
+			if tc.wantErr != (err != nil) {
+				t.Errorf("ParseVisibility() = %v; wantErr = %v", err, tc.wantErr)
+			}
+
+			if got != tc.want {
+				t.Errorf("ParseVisibility() = %s; want = %s", got, tc.want)
+			}
+
+			if !tc.wantErr && got.String() != tc.str {
+				t.Errorf("Visibility.String() = %s; want = %s", got, tc.str)
+			}
+		})
+	}
+}